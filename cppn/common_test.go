@@ -29,3 +29,64 @@ func printGraph(graph SubstrateGraphBuilder, t *testing.T) {
 	assert.NoError(t, err)
 	t.Log(buf.String())
 }
+
+func activateForward(solver network.Solver, t *testing.T) []float64 {
+	signals := []float64{0.9, 5.2, 1.2, 0.6}
+	err := solver.LoadSensors(signals)
+	require.NoError(t, err, "failed to load sensors")
+
+	res, err := solver.ForwardSteps(3)
+	require.NoError(t, err, "failed to perform forward activation")
+	require.True(t, res, "failed to activate network")
+
+	return solver.ReadOutputs()
+}
+
+// Returns the names of activation functions of the graph nodes by node ID
+func graphNodeActivations(builder SubstrateGraphBuilder, t *testing.T) map[int]string {
+	graph, err := builder.(*graphMLBuilder).graph()
+	require.NoError(t, err, "failed to get graph")
+	activations := make(map[int]string, len(graph.Nodes))
+	for _, gNode := range graph.Nodes {
+		attributes, err := gNode.GetAttributes()
+		require.NoError(t, err, "failed to get node attributes")
+		activations[attributes[nodeAttrID].(int)] = attributes[nodeAttrNodeActivationType].(string)
+	}
+	return activations
+}
+
+// recordingSolver records all inputs loaded into the wrapped CPPN solver
+type recordingSolver struct {
+	network.Solver
+	inputs [][]float64
+}
+
+func (r *recordingSolver) LoadSensors(inputs []float64) error {
+	r.inputs = append(r.inputs, append([]float64(nil), inputs...))
+	return r.Solver.LoadSensors(inputs)
+}
+
+// extraOutputsSolver appends the constant extra outputs to the outputs of the wrapped CPPN solver
+type extraOutputsSolver struct {
+	network.Solver
+	extra []float64
+}
+
+func (e *extraOutputsSolver) ReadOutputs() []float64 {
+	return append(e.Solver.ReadOutputs(), e.extra...)
+}
+
+// cancellingSolver cancels the context after the specified number of inputs loaded into the wrapped CPPN solver
+type cancellingSolver struct {
+	network.Solver
+	cancel func()
+	after  int
+	loads  int
+}
+
+func (c *cancellingSolver) LoadSensors(inputs []float64) error {
+	if c.loads++; c.loads == c.after {
+		c.cancel()
+	}
+	return c.Solver.LoadSensors(inputs)
+}
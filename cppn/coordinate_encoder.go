@@ -0,0 +1,188 @@
+package cppn
+
+import (
+	"github.com/pkg/errors"
+	"github.com/yaricom/goNEAT/v4/neat/genetics"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"math"
+)
+
+// CoordinateEncoder Defines the encoder of the hypercube point, i.e. the coordinates of the source and target neurons
+// of the substrate, into the inputs of the CPPN.
+type CoordinateEncoder interface {
+	// Width Returns the number of CPPN inputs produced by this encoder
+	Width() int
+	// Encode Writes the CPPN inputs encoding given source and target coordinates into the provided inputs
+	// buffer, which has the length of Width()
+	Encode(source, target PointF, inputs []float64)
+}
+
+// CartesianEncoder Encodes coordinates as is: [x1, y1, z1, x2, y2, z2], or [x1, y1, x2, y2] if OmitZ is true
+type CartesianEncoder struct {
+	// OmitZ The flag to indicate whether Z coordinates should be omitted for the 2D substrates
+	OmitZ bool
+}
+
+func (e CartesianEncoder) Width() int {
+	if e.OmitZ {
+		return 4
+	}
+	return 6
+}
+
+func (e CartesianEncoder) Encode(source, target PointF, inputs []float64) {
+	if e.OmitZ {
+		inputs[0], inputs[1] = source.X, source.Y
+		inputs[2], inputs[3] = target.X, target.Y
+	} else {
+		inputs[0], inputs[1], inputs[2] = source.X, source.Y, source.Z
+		inputs[3], inputs[4], inputs[5] = target.X, target.Y, target.Z
+	}
+}
+
+// DistanceEncoder Encodes the Euclidean distance between source and target points. The Z coordinate is ignored if
+// OmitZ is true
+type DistanceEncoder struct {
+	// OmitZ The flag to indicate whether Z coordinates should be omitted for the 2D substrates
+	OmitZ bool
+}
+
+func (e DistanceEncoder) Width() int {
+	return 1
+}
+
+func (e DistanceEncoder) Encode(source, target PointF, inputs []float64) {
+	dz := target.Z - source.Z
+	if e.OmitZ {
+		dz = 0
+	}
+	inputs[0] = math.Sqrt(math.Pow(target.X-source.X, 2) + math.Pow(target.Y-source.Y, 2) + dz*dz)
+}
+
+// DeltaEncoder Encodes the per-axis deltas between target and source points: [x2 - x1, y2 - y1, z2 - z1], or
+// [x2 - x1, y2 - y1] if OmitZ is true
+type DeltaEncoder struct {
+	// OmitZ The flag to indicate whether Z coordinates should be omitted for the 2D substrates
+	OmitZ bool
+}
+
+func (e DeltaEncoder) Width() int {
+	if e.OmitZ {
+		return 2
+	}
+	return 3
+}
+
+func (e DeltaEncoder) Encode(source, target PointF, inputs []float64) {
+	inputs[0] = target.X - source.X
+	inputs[1] = target.Y - source.Y
+	if !e.OmitZ {
+		inputs[2] = target.Z - source.Z
+	}
+}
+
+// PolarEncoder Encodes the polar coordinates of the source and target points in the XY plane: [r1, θ1, r2, θ2], where
+// r is the radial distance from the origin and θ is the angle in radians in the range [-π, π]
+type PolarEncoder struct{}
+
+func (e PolarEncoder) Width() int {
+	return 4
+}
+
+func (e PolarEncoder) Encode(source, target PointF, inputs []float64) {
+	inputs[0], inputs[1] = math.Hypot(source.X, source.Y), math.Atan2(source.Y, source.X)
+	inputs[2], inputs[3] = math.Hypot(target.X, target.Y), math.Atan2(target.Y, target.X)
+}
+
+// BiasEncoder Encodes the constant CPPN bias value
+type BiasEncoder struct {
+	// Bias The value of the CPPN bias
+	Bias float64
+}
+
+func (e BiasEncoder) Width() int {
+	return 1
+}
+
+func (e BiasEncoder) Encode(_, _ PointF, inputs []float64) {
+	inputs[0] = e.Bias
+}
+
+// CompositeEncoder Combines CPPN inputs produced by the list of encoders in the order of the list
+type CompositeEncoder []CoordinateEncoder
+
+func (e CompositeEncoder) Width() int {
+	width := 0
+	for _, encoder := range e {
+		width += encoder.Width()
+	}
+	return width
+}
+
+func (e CompositeEncoder) Encode(source, target PointF, inputs []float64) {
+	offset := 0
+	for _, encoder := range e {
+		width := encoder.Width()
+		encoder.Encode(source, target, inputs[offset:offset+width])
+		offset += width
+	}
+}
+
+// NewCoordinateEncoder Creates the encoder producing Cartesian coordinates of the source and target points followed by
+// the provided extra inputs, e.g., distance between points or per-axis deltas.
+func NewCoordinateEncoder(omitZ bool, extras ...CoordinateEncoder) CoordinateEncoder {
+	if len(extras) == 0 {
+		return CartesianEncoder{OmitZ: omitZ}
+	}
+	return append(CompositeEncoder{CartesianEncoder{OmitZ: omitZ}}, extras...)
+}
+
+// NewBiasedCoordinateEncoder Creates the encoder which provides cppnBias value as the first CPPN input followed by
+// inputs of the given encoder.
+func NewBiasedCoordinateEncoder(cppnBias float64, encoder CoordinateEncoder) CoordinateEncoder {
+	return CompositeEncoder{BiasEncoder{Bias: cppnBias}, encoder}
+}
+
+// ValidateCoordinateEncoder Checks that the number of inputs of the CPPN genome matches the width of the provided
+// encoder. The CPPN BIAS nodes can be either loaded by the encoder or have the default value.
+func ValidateCoordinateEncoder(encoder CoordinateEncoder, genome *genetics.Genome) error {
+	inputs, sensors := 0, 0
+	for _, node := range genome.Nodes {
+		if node.NeuronType == network.InputNeuron {
+			inputs++
+		}
+		if node.IsSensor() {
+			sensors++
+		}
+	}
+	return validateEncoderWidth(encoder, inputs, sensors)
+}
+
+// Checks that the number of inputs of the CPPN network matches the width of the provided encoder.
+func validateNetworkEncoder(encoder CoordinateEncoder, net *network.Network) error {
+	inputs, sensors := 0, 0
+	for _, node := range net.BaseNodes() {
+		if node.NeuronType == network.InputNeuron {
+			inputs++
+		}
+		if node.IsSensor() {
+			sensors++
+		}
+	}
+	return validateEncoderWidth(encoder, inputs, sensors)
+}
+
+func validateEncoderWidth(encoder CoordinateEncoder, inputs, sensors int) error {
+	if width := encoder.Width(); width != inputs && width != sensors {
+		return errors.Errorf("CPPN inputs number [%d] or sensors number [%d] does not match the coordinate encoder width [%d]",
+			inputs, sensors, width)
+	}
+	return nil
+}
+
+// Encodes the provided source and target points into the new CPPN inputs array using given encoder
+func encodeCoordinates(encoder CoordinateEncoder, source, target PointF) []float64 {
+	inputs := make([]float64, encoder.Width())
+	encoder.Encode(source, target, inputs)
+	return inputs
+}
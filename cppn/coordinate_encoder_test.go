@@ -0,0 +1,88 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goNEAT/v4/neat/genetics"
+	"math"
+	"testing"
+)
+
+func TestCoordinateEncoders(t *testing.T) {
+	source := PointF{X: 0.5, Y: -0.5, Z: 1.0}
+	target := PointF{X: -1.0, Y: 1.0, Z: 0.0}
+
+	testCases := []struct {
+		name     string
+		encoder  CoordinateEncoder
+		expected []float64
+	}{
+		{
+			name:     "cartesian",
+			encoder:  CartesianEncoder{},
+			expected: []float64{0.5, -0.5, 1.0, -1.0, 1.0, 0.0},
+		},
+		{
+			name:     "cartesian 2D",
+			encoder:  CartesianEncoder{OmitZ: true},
+			expected: []float64{0.5, -0.5, -1.0, 1.0},
+		},
+		{
+			name:     "distance",
+			encoder:  DistanceEncoder{},
+			expected: []float64{math.Sqrt(2.25 + 2.25 + 1.0)},
+		},
+		{
+			name:     "distance 2D",
+			encoder:  DistanceEncoder{OmitZ: true},
+			expected: []float64{math.Sqrt(2.25 + 2.25)},
+		},
+		{
+			name:     "delta",
+			encoder:  DeltaEncoder{},
+			expected: []float64{-1.5, 1.5, -1.0},
+		},
+		{
+			name:     "delta 2D",
+			encoder:  DeltaEncoder{OmitZ: true},
+			expected: []float64{-1.5, 1.5},
+		},
+		{
+			name:     "polar",
+			encoder:  PolarEncoder{},
+			expected: []float64{math.Sqrt(0.5), -math.Pi / 4, math.Sqrt(2.0), 3 * math.Pi / 4},
+		},
+		{
+			name:     "bias",
+			encoder:  BiasEncoder{Bias: 0.33},
+			expected: []float64{0.33},
+		},
+		{
+			name:     "biased cartesian with distance",
+			encoder:  NewBiasedCoordinateEncoder(0.33, NewCoordinateEncoder(true, DistanceEncoder{OmitZ: true})),
+			expected: []float64{0.33, 0.5, -0.5, -1.0, 1.0, math.Sqrt(2.25 + 2.25)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, len(tc.expected), tc.encoder.Width(), "wrong encoder width")
+			inputs := encodeCoordinates(tc.encoder, source, target)
+			assert.InDeltaSlice(t, tc.expected, inputs, 1e-12)
+		})
+	}
+}
+
+func TestValidateCoordinateEncoder(t *testing.T) {
+	reader, err := genetics.NewGenomeReaderFromFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to open genome file")
+	genome, err := reader.Read()
+	require.NoError(t, err, "failed to read genome")
+
+	// six inputs without BIAS
+	assert.NoError(t, ValidateCoordinateEncoder(NewCoordinateEncoder(false), genome))
+	// six inputs with BIAS
+	assert.NoError(t, ValidateCoordinateEncoder(NewBiasedCoordinateEncoder(0.5, NewCoordinateEncoder(false)), genome))
+	// wrong number of inputs
+	err = ValidateCoordinateEncoder(NewCoordinateEncoder(true), genome)
+	assert.EqualError(t, err, "CPPN inputs number [6] or sensors number [7] does not match the coordinate encoder width [4]")
+}
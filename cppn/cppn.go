@@ -3,11 +3,10 @@
 package cppn
 
 import (
+	"context"
 	"errors"
 	"github.com/yaricom/goNEAT/v4/neat/genetics"
 	"github.com/yaricom/goNEAT/v4/neat/network"
-	"gonum.org/v1/gonum/stat"
-	"math"
 )
 
 // FastSolverFromGenomeFile Reads CPPN from specified genome and creates network solver
@@ -32,25 +31,80 @@ func NetworkFromGenomeFile(genomePath string) (*network.Network, error) {
 	}
 }
 
-// Creates normalized by threshold value link between source and target nodes, given calculated CPPN output for their coordinates
-func createThresholdNormalizedLink(cppnOutput float64, srcIndex, dstIndex int, linkThreshold, weightRange float64) *network.FastNetworkLink {
-	weight := (math.Abs(cppnOutput) - linkThreshold) / (1 - linkThreshold) // normalize [0, 1]
-	weight *= weightRange                                                  // scale to fit a given weight range
-	if math.Signbit(cppnOutput) {
-		weight *= -1 // restore sign
+// Creates a deep copy of the provided CPPN network which can be activated independently of the original one.
+func copyNetwork(net *network.Network) (*network.Network, error) {
+	nodes := net.AllNodes()
+	nodesMap := make(map[*network.NNode]*network.NNode, len(nodes))
+	for _, n := range nodes {
+		node := network.NewNNodeCopy(n, n.Trait)
+		if n.Params != nil {
+			node.Params = append([]float64(nil), n.Params...)
+		}
+		nodesMap[n] = node
 	}
-	link := network.FastNetworkLink{
-		Weight:      weight,
-		SourceIndex: srcIndex,
-		TargetIndex: dstIndex,
+
+	linksMap := make(map[*network.Link]*network.Link)
+	copyLinks := func(links []*network.Link) ([]*network.Link, error) {
+		copies := make([]*network.Link, len(links))
+		for i, l := range links {
+			if link, ok := linksMap[l]; ok {
+				copies[i] = link
+				continue
+			}
+			inNode, inOk := nodesMap[l.InNode]
+			outNode, outOk := nodesMap[l.OutNode]
+			if !inOk || !outOk {
+				return nil, errors.New("link connects node which is not part of the network")
+			}
+			copies[i] = network.NewLinkCopy(l, inNode, outNode)
+			copies[i].IsTimeDelayed = l.IsTimeDelayed
+			linksMap[l] = copies[i]
+		}
+		return copies, nil
 	}
-	return &link
+
+	var err error
+	for _, n := range nodes {
+		node := nodesMap[n]
+		if node.Incoming, err = copyLinks(n.Incoming); err != nil {
+			return nil, err
+		}
+		if node.Outgoing, err = copyLinks(n.Outgoing); err != nil {
+			return nil, err
+		}
+	}
+
+	// the inputs are sensors in the order of base nodes list
+	baseNodes := net.BaseNodes()
+	in := make([]*network.NNode, 0)
+	all := make([]*network.NNode, len(baseNodes))
+	for i, n := range baseNodes {
+		all[i] = nodesMap[n]
+		if n.IsSensor() {
+			in = append(in, all[i])
+		}
+	}
+	out := make([]*network.NNode, len(net.Outputs))
+	for i, n := range net.Outputs {
+		out[i] = nodesMap[n]
+	}
+
+	var netCopy *network.Network
+	if controlNodes := net.ControlNodes(); len(controlNodes) > 0 {
+		control := make([]*network.NNode, len(controlNodes))
+		for i, n := range controlNodes {
+			control[i] = nodesMap[n]
+		}
+		netCopy = network.NewModularNetwork(in, out, all, control, net.Id)
+	} else {
+		netCopy = network.NewNetwork(in, out, all, net.Id)
+	}
+	netCopy.Name = net.Name
+	return netCopy, nil
 }
 
-// Creates a link between source and target nodes, given calculated CPPN output for their coordinates
-func createLink(cppnOutput float64, srcIndex, dstIndex int, weightRange float64) *network.FastNetworkLink {
-	weight := cppnOutput
-	weight *= weightRange // scale to fit a given weight range
+// Creates a link with given weight between source and target nodes
+func createLink(weight float64, srcIndex, dstIndex int) *network.FastNetworkLink {
 	link := network.FastNetworkLink{
 		Weight:      weight,
 		SourceIndex: srcIndex,
@@ -81,6 +135,60 @@ func queryCPPN(coordinates []float64, cppn network.Solver) ([]float64, error) {
 	return cppn.ReadOutputs(), nil
 }
 
+// QueryCPPNBatch Calculates outputs of the provided CPPN network solver for each of the given hypercube coordinates
+// tuples. The outputs are returned in the same order as provided coordinates. If the CPPN is *network.Network, its
+// activation depth is calculated only once per batch rather than for each tuple. Other solvers are activated by
+// RecursiveSteps for each tuple, the same way as when they are queried separately.
+func QueryCPPNBatch(coordinates [][]float64, cppn network.Solver) ([][]float64, error) {
+	return QueryCPPNBatchContext(context.Background(), coordinates, cppn)
+}
+
+// QueryCPPNBatchContext Calculates outputs of the provided CPPN network solver for each of the given hypercube
+// coordinates tuples the same way as QueryCPPNBatch. The context is checked before each query, and if it is cancelled
+// or its deadline exceeded, the context error is returned.
+func QueryCPPNBatchContext(ctx context.Context, coordinates [][]float64, cppn network.Solver) ([][]float64, error) {
+	outputs := make([][]float64, len(coordinates))
+	if len(coordinates) == 0 {
+		return outputs, nil
+	}
+
+	activate := cppn.RecursiveSteps
+	if net, ok := cppn.(*network.Network); ok {
+		// the activation depth of the CPPN network is the same for all queries
+		if depth, err := net.MaxActivationDepthWithCap(0); err != nil {
+			return nil, err
+		} else {
+			activate = func() (bool, error) {
+				return net.ForwardSteps(depth)
+			}
+		}
+	}
+
+	for i, coords := range coordinates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// flush networks activation from the previous run
+		if res, err := cppn.Flush(); err != nil {
+			return nil, err
+		} else if !res {
+			return nil, errors.New("failed to flush CPPN network")
+		}
+		// load inputs
+		if err := cppn.LoadSensors(coords); err != nil {
+			return nil, err
+		}
+		// do activations
+		if res, err := activate(); err != nil {
+			return nil, err
+		} else if !res {
+			return nil, errors.New("failed to relax CPPN network recursively")
+		}
+		outputs[i] = cppn.ReadOutputs()
+	}
+	return outputs, nil
+}
+
 //func queryCPPNNetwork(coordinates []float64, cppn *network.Network) ([]float64, error) {
 //	if res, err := cppn.Flush(); err != nil {
 //		return nil, err
@@ -110,32 +218,3 @@ func queryCPPN(coordinates []float64, cppn network.Solver) ([]float64, error) {
 //
 //	return cppn.ReadOutputs(), nil
 //}
-
-// Determines variance among CPPN values for a certain hypercube region around a specified node.
-// This variance is a heuristic indicator of a region's heterogeneity (i.e., presence of information).
-func nodeVariance(node *QuadNode) float64 {
-	// quick check
-	if len(node.Nodes) < 4 {
-		return 0.0
-	}
-
-	// node always has four child nodes if any
-	cppnValues := []float64{node.Nodes[0].Weight(), node.Nodes[1].Weight(), node.Nodes[2].Weight(), node.Nodes[3].Weight()}
-	return stat.Variance(cppnValues, nil)
-}
-
-// Collects the CPPN values stored in a given quadtree node.
-// Used to estimate the variance in a certain region of space around the node.
-func nodeCPPNValues(n *QuadNode) []float64 {
-	if len(n.Nodes) > 0 {
-		accumulator := make([]float64, 0)
-		for _, p := range n.Nodes {
-			// go into child nodes
-			cppnValues := nodeCPPNValues(p)
-			accumulator = append(accumulator, cppnValues...)
-		}
-		return accumulator
-	} else {
-		return []float64{n.Weight()}
-	}
-}
@@ -1,6 +1,7 @@
 package cppn
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -11,25 +12,6 @@ const (
 	cppnLeoHyperNEATTestGenomePath = "../data/test/test_cppn_leo_hyperneat_genome.yml"
 )
 
-func TestQuadNode_NodeVariance(t *testing.T) {
-	root := buildTree()
-
-	// get variance and check results
-	variance := nodeVariance(root)
-	assert.InDelta(t, 1.6666666666666667, variance, 1e-16)
-}
-
-func TestQuadNode_nodeCPPNValues(t *testing.T) {
-	root := buildTree()
-
-	// get CPPN values and test results
-	vals := nodeCPPNValues(root)
-	require.Len(t, vals, 7, "wrong node values length")
-
-	expected := []float64{0, 1, 2, 3, 1, 2, 3}
-	assert.ElementsMatch(t, expected, vals)
-}
-
 func TestFastSolverFromGenomeFile(t *testing.T) {
 	cppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
 	require.NoError(t, err, "failed to read genome file")
@@ -64,6 +46,78 @@ func TestFastSolverFromGenomeFile_LEO(t *testing.T) {
 	assert.Equal(t, 1.0, outs[1], "wrong LEO value")
 }
 
+func TestQueryCPPNBatch(t *testing.T) {
+	cppn, err := FastSolverFromGenomeFile(cppnLeoHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read genome file")
+
+	coordinates := [][]float64{
+		{0.0, 0.0, 0.0, 0.5, 0.5, 0.0},
+		{-1.0, -1.0, 0.0, 1.0, 1.0, 0.0},
+		{0.5, -0.5, 0.0, -0.5, 0.5, 0.0},
+	}
+	outs, err := QueryCPPNBatch(coordinates, cppn)
+	require.NoError(t, err, "failed to query CPPN")
+	require.Len(t, outs, len(coordinates))
+
+	// check that batch results are the same as results of separate queries
+	for i, coords := range coordinates {
+		expected, err := queryCPPN(coords, cppn)
+		require.NoError(t, err, "failed to query CPPN")
+		assert.Equal(t, expected, outs[i], "wrong outputs at: %d", i)
+	}
+}
+
+func TestQueryCPPNBatch_Empty(t *testing.T) {
+	cppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read genome file")
+
+	outs, err := QueryCPPNBatch(nil, cppn)
+	require.NoError(t, err, "failed to query CPPN")
+	assert.Empty(t, outs)
+}
+
+func TestQueryCPPNBatchContext_Cancelled(t *testing.T) {
+	fastCppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read genome file")
+	coordinates := [][]float64{
+		{0.0, 0.0, 0.0, 0.5, 0.5, 0.0},
+		{-1.0, -1.0, 0.0, 1.0, 1.0, 0.0},
+		{0.5, -0.5, 0.0, -0.5, 0.5, 0.0},
+	}
+
+	// the context cancelled during the batch query
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cppn := &cancellingSolver{Solver: fastCppn, cancel: cancel, after: 1}
+	outs, err := QueryCPPNBatchContext(ctx, coordinates, cppn)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, outs)
+	assert.Equal(t, 1, cppn.loads, "no queries expected after cancellation")
+}
+
+func TestCopyNetwork(t *testing.T) {
+	net, err := NetworkFromGenomeFile(cppnLeoHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read genome file")
+
+	netCopy, err := copyNetwork(net)
+	require.NoError(t, err, "failed to copy network")
+	assert.Equal(t, net.NodeCount(), netCopy.NodeCount(), "wrong nodes number")
+	assert.Equal(t, net.LinkCount(), netCopy.LinkCount(), "wrong links number")
+
+	// check that copy produces the same outputs
+	coords := []float64{0.0, 0.0, 0.0, 0.5, 0.5, 0.0}
+	outs, err := queryCPPN(coords, net)
+	require.NoError(t, err, "failed to query CPPN")
+	outsCopy, err := queryCPPN(coords, netCopy)
+	require.NoError(t, err, "failed to query CPPN copy")
+	assert.Equal(t, outs, outsCopy)
+
+	// check that copy is independent of the original
+	for i, n := range net.AllNodes() {
+		assert.NotSame(t, n, netCopy.AllNodes()[i], "node shared at: %d", i)
+	}
+}
+
 func buildTree() *QuadNode {
 	root := NewQuadNode(0, 0, 1, 1, 1)
 	root.Nodes = []*QuadNode{
@@ -1,13 +1,15 @@
 package cppn
 
 import (
-	"container/list"
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"github.com/yaricom/goNEAT/v4/neat"
 	neatmath "github.com/yaricom/goNEAT/v4/neat/math"
 	"github.com/yaricom/goNEAT/v4/neat/network"
-	"math"
+	"strings"
+	"sync"
 )
 
 // EvolvableSubstrate The evolvable substrate holds configuration of ANN produced by CPPN within the hypercube where
@@ -23,16 +25,49 @@ type EvolvableSubstrate struct {
 	// OutputNodesActivation The activation function type for output neurons encoded
 	OutputNodesActivation neatmath.NodeActivationType
 
-	// The CPPN network solver to describe the geometry of substrate
-	cppn *network.Network
-	// The reusable coordinates buffer
-	coords []float64
+	// Encoder The encoder of the hypercube coordinates into the CPPN inputs
+	Encoder CoordinateEncoder
+	// LinkExpression The strategy to decide whether to express a link between substrate nodes. If not set, the
+	// strategy defined by HyperNEAT options is used.
+	LinkExpression LinkExpressionStrategy
+	// Variance The function to estimate the variance of CPPN outputs within the quadtree node region. If not set, the
+	// function defined by ES-HyperNEAT options is used.
+	Variance VarianceFunction
+	// Placement The constraint of where hidden nodes can be placed. If not set, the constraint defined by ES-HyperNEAT
+	// options is used.
+	Placement PlacementConstraint
+
+	// The CPPN query cache statistics collected during last network solver creation
+	cacheStats QueryCacheStats
+	// The statistics of hidden nodes discovery iterations collected during last network solver creation
+	iterationStats []ESIterationStats
+	// The number of hidden nodes pruned during last network solver creation
+	prunedHiddenCount int
+	// The resources which limits were exceeded during last network solver creation
+	exceededResources []ResourceType
+}
+
+// ESIterationStats The statistics of one iteration of the hidden nodes discovery
+type ESIterationStats struct {
+	// Iteration The number of iteration starting from one
+	Iteration int
+	// ExploredNodes The number of hidden nodes discovered by the previous iteration and explored in this iteration
+	ExploredNodes int
+	// NewNodes The number of new hidden nodes discovered in this iteration
+	NewNodes int
+	// NewLinks The number of new links expressed in this iteration
+	NewLinks int
+}
+
+func (s ESIterationStats) String() string {
+	return fmt.Sprintf("iteration: %d, explored nodes: %d, new nodes: %d, new links: %d",
+		s.Iteration, s.ExploredNodes, s.NewNodes, s.NewLinks)
 }
 
 // NewEvolvableSubstrate Creates new instance of evolvable substrate
 func NewEvolvableSubstrate(layout EvolvableSubstrateLayout, hiddenNodesActivation, outputNodesActivation neatmath.NodeActivationType) *EvolvableSubstrate {
 	return &EvolvableSubstrate{
-		coords:                make([]float64, 6),
+		Encoder:               NewCoordinateEncoder(false),
 		Layout:                layout,
 		HiddenNodesActivation: hiddenNodesActivation,
 		OutputNodesActivation: outputNodesActivation,
@@ -42,10 +77,8 @@ func NewEvolvableSubstrate(layout EvolvableSubstrateLayout, hiddenNodesActivatio
 // NewEvolvableSubstrateWithBias creates new instance of evolvable substrate with defined cppnBias value.
 // The cppnBias will be provided as the first value of the CPPN inputs array.
 func NewEvolvableSubstrateWithBias(layout EvolvableSubstrateLayout, hiddenNodesActivation, outputNodesActivation neatmath.NodeActivationType, cppnBias float64) *EvolvableSubstrate {
-	coords := make([]float64, 7)
-	coords[0] = cppnBias
 	return &EvolvableSubstrate{
-		coords:                coords,
+		Encoder:               NewBiasedCoordinateEncoder(cppnBias, NewCoordinateEncoder(false)),
 		Layout:                layout,
 		HiddenNodesActivation: hiddenNodesActivation,
 		OutputNodesActivation: outputNodesActivation,
@@ -56,39 +89,131 @@ func NewEvolvableSubstrateWithBias(layout EvolvableSubstrateLayout, hiddenNodesA
 // Compositional Pattern Producing Network, which used to define connections between network nodes.
 // Optional graph_builder can be provided to collect graph nodes and edges of the created network solver.
 // With graph builder it is possible to save/load network configuration as well as visualize it.
+// If node biases are enabled by options, the BIAS neuron will be added to the network and the bias of each hidden and
+// output node will be queried from CPPN at the node position. Note that biases are applied by the created solver only
+// during forward activation steps. Similarly, if the palette of node activators is defined by options, the activation
+// function of each hidden and output node will be selected by CPPN. The activation functions defined by the layout
+// implementing NodeActivationLayout take precedence over both. If plasticity is enabled by options, the Hebbian
+// learning rule of each link is read from the CPPN outputs of that link and the PlasticNetworkSolver is created. If
+// neuromodulation is enabled as well, the CPPN decides which hidden nodes are modulatory.
+// By default, the created network is feedforward. The recurrent links between hidden nodes, self-loops, and feedback
+// links from output to hidden nodes can be enabled by options.
+// If hidden nodes pruning is enabled by options, the hidden nodes that are not on any path from the inputs to the
+// outputs are removed from the created network along with their links, and the remaining hidden nodes are re-indexed
+// preserving their order. The substrate layout keeps all discovered hidden nodes, unless it implements the
+// ResettableSubstrateLayout, in which case it is reset before the creation.
+// If resource limits are defined by options, exceeding any of them results in *ResourceLimitError, or in dropping of
+// the exceeding hidden nodes, links, and connectivity patterns if the truncate policy is set.
 func (es *EvolvableSubstrate) CreateNetworkSolver(cppn *network.Network, graphBuilder SubstrateGraphBuilder, options *eshyperneat.Options) (network.Solver, error) {
-	es.cppn = cppn
+	return es.CreateNetworkSolverContext(context.Background(), cppn, graphBuilder, options)
+}
+
+// CreateNetworkSolverContext Creates a network solver the same way as CreateNetworkSolver, but checks the provided
+// context for cancellation and deadline between the quadtree expansions and CPPN queries. If the context is cancelled
+// or its deadline exceeded, the creation is interrupted and the context error is returned.
+func (es *EvolvableSubstrate) CreateNetworkSolverContext(ctx context.Context, cppn *network.Network, graphBuilder SubstrateGraphBuilder, options *eshyperneat.Options) (network.Solver, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// forget the hidden nodes discovered for the previous CPPN
+	if resettable, ok := es.Layout.(ResettableSubstrateLayout); ok {
+		resettable.Reset()
+	}
+	if err := validateNetworkEncoder(es.Encoder, cppn); err != nil {
+		return nil, err
+	}
+	if err := es.validateExploredRegion(options); err != nil {
+		return nil, err
+	}
+	if err := validatePlasticityOptions(options.Options); err != nil {
+		return nil, err
+	}
+	// the strategy to decide whether to express links between nodes
+	expression := es.LinkExpression
+	if expression == nil {
+		var err error
+		if expression, err = NewLinkExpressionStrategy(options.Options); err != nil {
+			return nil, err
+		}
+	}
+	// the function to estimate the variance of CPPN outputs
+	variance := es.Variance
+	if variance == nil {
+		var err error
+		if variance, err = NewVarianceFunction(options); err != nil {
+			return nil, err
+		}
+	}
+	// the tracker of resources consumed against the limits
+	limits, err := newResourceLimits(options)
+	if err != nil {
+		return nil, err
+	}
+	// the explorers and their caches are scoped to the current CPPN
+	explorers, err := newQuadTreeExplorers(cppn, es.Encoder, expression, variance, limits, options)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		es.cacheStats = QueryCacheStats{}
+		for _, explorer := range explorers {
+			es.cacheStats = es.cacheStats.add(explorer.cacheStats())
+		}
+		es.exceededResources = limits.exceededResources()
+	}()
 
 	// the network layers will be collected in order: bias, input, output, hidden
-	firstInput := 0
+	biasCount := 0
+	if options.NodeBiasEnabled {
+		// the BIAS neuron to hold CPPN encoded node biases
+		biasCount = 1
+	}
+	firstInput := biasCount
 	firstOutput := firstInput + es.Layout.InputCount()
 	firstHidden := firstOutput + es.Layout.OutputCount()
 
+	// the encoder of the output and hidden nodes properties
+	nodes := newNodeEncoder(cppn, es.Encoder, options.Options, limits)
+	// the constraint of where hidden nodes can be placed
+	placement := es.Placement
+	if placement == nil {
+		placement = NewPlacementConstraint(options)
+	}
+
 	links := make([]*network.FastNetworkLink, 0)
 	// The map to hold already created links
 	connMap := make(map[string]*network.FastNetworkLink)
+	// The learning rules of plastic links if appropriate
+	rules := make(map[*network.FastNetworkLink]HebbianRule)
 
 	// The function to add a new link to the network if appropriate
-	addLink := func(qp *QuadPoint, source, target int) (*network.FastNetworkLink, bool) {
+	addLink := func(qp *QuadPoint, source, target int) error {
 		key := fmt.Sprintf("%d_%d", source, target)
 		if _, ok := connMap[key]; ok {
 			// connection already exists
-			return nil, false
-		}
-		var link *network.FastNetworkLink
-		if options.LeoEnabled && qp.Leo > 0 {
-			link = createLink(qp.Weight, source, target, options.WeightRange)
-		} else if !options.LeoEnabled && math.Abs(qp.Weight) >= options.LinkThreshold {
-			// add only connections with signal exceeding the provided threshold
-			link = createThresholdNormalizedLink(qp.Weight, source, target, options.LinkThreshold, options.WeightRange)
-		}
-		if link != nil {
-			links = append(links, link)
-			connMap[key] = link
-			return link, true
-		} else {
-			return nil, false
+			return nil
 		}
+		weight, ok := expression.ExpressLink(qp.Weight, qp.Leo,
+			PointF{X: qp.X1, Y: qp.Y1, Z: qp.Z1}, PointF{X: qp.X2, Y: qp.Y2, Z: qp.Z2})
+		if !ok {
+			return nil
+		}
+		if ok, err := limits.allowLink(len(links)); err != nil {
+			return err
+		} else if !ok {
+			return nil
+		}
+		link := createLink(weight, source, target)
+		if options.PlasticityEnabled {
+			rule, err := newHebbianRule(qp.CppnOut, options.Options)
+			if err != nil {
+				return err
+			}
+			rules[link] = rule
+		}
+		links = append(links, link)
+		connMap[key] = link
+		return nil
 	}
 
 	// inline function to find an activation type for a given neuron
@@ -98,134 +223,249 @@ func (es *EvolvableSubstrate) CreateNetworkSolver(cppn *network.Network, graphBu
 			return neatmath.LinearActivation
 		} else if nodeIndex < firstHidden {
 			// output nodes activations
-			return es.OutputNodesActivation
+			return layoutActivation(es.Layout, nodeIndex-firstOutput, network.OutputNeuron,
+				nodes.activation(nodeIndex, es.OutputNodesActivation))
 		} else {
 			// hidden nodes activation
-			return es.HiddenNodesActivation
+			return layoutActivation(es.Layout, nodeIndex-firstHidden, network.HiddenNeuron,
+				nodes.activation(nodeIndex, es.HiddenNodesActivation))
 		}
 	}
 
 	// Build links from input nodes to the hidden nodes
-	var root *QuadNode
+	inputs := make([]*PointF, 0, es.Layout.InputCount())
 	for in := firstInput; in < firstOutput; in++ {
-		// Analyse an outgoing connectivity pattern from this input
 		input, err := es.Layout.NodePosition(in-firstInput, network.InputNeuron)
 		if err != nil {
 			return nil, err
 		}
-		// add input node to graph
-		if _, err = addNodeToBuilder(graphBuilder, in, network.InputNeuron, activationForNeuron(in), input); err != nil {
-			return nil, err
-		}
-
-		if root, err = es.quadTreeDivideAndInit(input.X, input.Y, input.Z, true, options); err != nil {
-			return nil, err
-		}
-		qPoints := make([]*QuadPoint, 0)
-		if qPoints, err = es.pruneAndExpress(input.X, input.Y, input.Z, qPoints, root, true, options); err != nil {
-			return nil, err
-		}
+		inputs = append(inputs, input)
+	}
+	// Analyse an outgoing connectivity pattern from each input
+	patterns, err := explorePatterns(ctx, explorers, inputs, true, options)
+	if err != nil {
+		return nil, err
+	}
+	for i, qPoints := range patterns {
+		in := firstInput + i
 		// iterate over quad points and add nodes/links
 		for _, qp := range qPoints {
 			// add a hidden node to the substrate layout if needed
-			targetIndex, err := es.addHiddenNode(qp, firstHidden, graphBuilder)
+			targetIndex, err := es.addHiddenNode(ctx, qp, firstHidden, nodes, placement, limits)
 			if err != nil {
 				return nil, err
+			} else if targetIndex == -1 {
+				// the hidden node is not allowed at this position - drop connection
+				continue
 			}
 			// add connection
-			if link, ok := addLink(qp, in, targetIndex); ok {
-				// add an edge to the graph
-				if _, err = addEdgeToBuilder(graphBuilder, in, targetIndex, link.Weight); err != nil {
-					return nil, err
-				}
+			if err = addLink(qp, in, targetIndex); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	// Build more hidden nodes into unexplored area through a number of iterations
-	firstHiddenIter := firstHidden
-	lastHidden := firstHiddenIter + es.Layout.HiddenCount()
+	// Build more hidden nodes into unexplored area through a number of iterations. Each iteration explores only the
+	// hidden nodes discovered by the previous one, i.e., the window of layout indexes [firstUnexplored, lastUnexplored).
+	es.iterationStats = make([]ESIterationStats, 0, options.ESIterations)
+	firstUnexplored := 0
 	for step := 0; step < options.ESIterations; step++ {
-		for hi := firstHiddenIter; hi < lastHidden; hi++ {
-			// Analyse an outgoing connectivity pattern from this hidden node
-			hidden, err := es.Layout.NodePosition(hi-firstHidden, network.HiddenNeuron)
+		lastUnexplored := es.Layout.HiddenCount()
+		if firstUnexplored == lastUnexplored {
+			// no new hidden nodes discovered - nothing to explore
+			break
+		}
+		hiddens := make([]*PointF, 0, lastUnexplored-firstUnexplored)
+		for h := firstUnexplored; h < lastUnexplored; h++ {
+			hidden, err := es.Layout.NodePosition(h, network.HiddenNeuron)
 			if err != nil {
 				return nil, err
 			}
-			if root, err = es.quadTreeDivideAndInit(hidden.X, hidden.Y, hidden.Z, true, options); err != nil {
-				return nil, err
-			}
-			qPoints := make([]*QuadPoint, 0)
-			if qPoints, err = es.pruneAndExpress(hidden.X, hidden.Y, hidden.Z, qPoints, root, true, options); err != nil {
-				return nil, err
-			}
+			hiddens = append(hiddens, hidden)
+		}
+		linksCount := len(links)
+		// Analyse an outgoing connectivity pattern from each hidden node
+		patterns, err := explorePatterns(ctx, explorers, hiddens, true, options)
+		if err != nil {
+			return nil, err
+		}
+		for i, qPoints := range patterns {
+			hi := firstHidden + firstUnexplored + i // adjust index to the global indexes space
 			// iterate over quad points and add nodes/links
 			for _, qp := range qPoints {
 				// add a hidden node to the substrate layout if needed
-				targetIndex, err := es.addHiddenNode(qp, firstHidden, graphBuilder)
+				targetIndex, err := es.addHiddenNode(ctx, qp, firstHidden, nodes, placement, limits)
 				if err != nil {
 					return nil, err
+				} else if targetIndex == -1 {
+					// the hidden node is not allowed at this position - drop connection
+					continue
+				}
+				if targetIndex == hi && options.DisableSelfLoopLinks || targetIndex < hi && options.DisableRecurrentHiddenLinks {
+					// the self-loop or recurrent link to the hidden node discovered before is disabled
+					continue
 				}
 				// add connection
-				if link, ok := addLink(qp, hi, targetIndex); ok {
-					// add an edge to the graph
-					if _, err = addEdgeToBuilder(graphBuilder, hi, targetIndex, link.Weight); err != nil {
-						return nil, err
-					}
+				if err = addLink(qp, hi, targetIndex); err != nil {
+					return nil, err
 				}
 			}
 		}
+		es.iterationStats = append(es.iterationStats, ESIterationStats{
+			Iteration:     step + 1,
+			ExploredNodes: len(hiddens),
+			NewNodes:      es.Layout.HiddenCount() - lastUnexplored,
+			NewLinks:      len(links) - linksCount,
+		})
 
 		// move to the next window
-		firstHiddenIter = lastHidden
-		lastHidden = firstHidden + es.Layout.HiddenCount()
+		firstUnexplored = lastUnexplored
 	}
 
 	// Connect hidden nodes to the output
+	outputIndexes := make([]int, 0, es.Layout.OutputCount())
+	outputs := make([]*PointF, 0, es.Layout.OutputCount())
 	for oi := firstOutput; oi < firstHidden; oi++ {
-		// Analyse an incoming connectivity pattern
 		output, err := es.Layout.NodePosition(oi-firstOutput, network.OutputNeuron)
 		if err != nil {
 			return nil, err
 		}
-		// add output node to graph
-		if _, err = addNodeToBuilder(graphBuilder, oi, network.OutputNeuron, activationForNeuron(oi), output); err != nil {
-			return nil, err
-		}
-
-		if root, err = es.quadTreeDivideAndInit(output.X, output.Y, output.Z, false, options); err != nil {
-			return nil, err
-		}
-		qPoints := make([]*QuadPoint, 0)
-		if qPoints, err = es.pruneAndExpress(output.X, output.Y, output.Z, qPoints, root, false, options); err != nil {
+		outputIndexes = append(outputIndexes, oi)
+		outputs = append(outputs, output)
+	}
+	// query CPPN for the output nodes properties if appropriate, the output nodes keep the default properties if CPPN
+	// queries limit exceeded under truncate policy
+	if ok, err := nodes.reserve(len(outputs)); err != nil {
+		return nil, err
+	} else if ok {
+		if err = nodes.query(ctx, outputIndexes, outputs); err != nil {
 			return nil, err
 		}
-
+	}
+	// Analyse an incoming connectivity pattern of each output
+	if patterns, err = explorePatterns(ctx, explorers, outputs, false, options); err != nil {
+		return nil, err
+	}
+	for i, qPoints := range patterns {
+		oi := firstOutput + i
 		// iterate over quad points and add nodes/links where appropriate
 		for _, qp := range qPoints {
-			nodePoint := NewPointF(qp.X1, qp.Y1)
+			nodePoint := &PointF{X: qp.X1, Y: qp.Y1, Z: qp.Z1}
 			sourceIndex := es.Layout.IndexOfHidden(nodePoint)
 			if sourceIndex != -1 {
 				// only connect to the hidden nodes that already exist and connected to the input/hidden nodes
 				sourceIndex += firstHidden // adjust index to the global indexes space
 
 				// add connection
-				if link, ok := addLink(qp, sourceIndex, oi); ok {
-					// add an edge to the graph
-					if _, err = addEdgeToBuilder(graphBuilder, sourceIndex, oi, link.Weight); err != nil {
-						return nil, err
-					}
+				if err = addLink(qp, sourceIndex, oi); err != nil {
+					return nil, err
 				}
 			}
 		}
 	}
 
-	totalNeuronCount := es.Layout.InputCount() + es.Layout.OutputCount() + es.Layout.HiddenCount()
+	if options.OutputFeedbackLinks {
+		// Analyse an outgoing connectivity pattern of each output to link it back to the hidden nodes
+		if patterns, err = explorePatterns(ctx, explorers, outputs, true, options); err != nil {
+			return nil, err
+		}
+		for i, qPoints := range patterns {
+			oi := firstOutput + i
+			for _, qp := range qPoints {
+				targetIndex := es.Layout.IndexOfHidden(&PointF{X: qp.X2, Y: qp.Y2, Z: qp.Z2})
+				if targetIndex == -1 {
+					// only connect to the hidden nodes that already exist
+					continue
+				}
+				targetIndex += firstHidden // adjust index to the global indexes space
+
+				// add connection
+				if err = addLink(qp, oi, targetIndex); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Prune the hidden nodes without a path from inputs to outputs if appropriate and re-index the remaining neurons.
+	// The neurons list holds the original index of each remaining neuron in the new order.
+	var connected []bool
+	if options.PruneHiddenNodes {
+		connected = connectedHiddenNodes(links, firstInput, firstOutput, firstHidden, es.Layout.HiddenCount())
+	}
+	neurons := make([]int, 0, firstHidden+es.Layout.HiddenCount())
+	newIndexes := make(map[int]int, firstHidden+es.Layout.HiddenCount())
+	for i := 0; i < firstHidden+es.Layout.HiddenCount(); i++ {
+		if i < firstHidden || connected == nil || connected[i-firstHidden] {
+			newIndexes[i] = len(neurons)
+			neurons = append(neurons, i)
+		}
+	}
+	es.prunedHiddenCount = firstHidden + es.Layout.HiddenCount() - len(neurons)
+	prunedLinks := make([]*network.FastNetworkLink, 0, len(links))
+	for _, link := range links {
+		source, sourceOk := newIndexes[link.SourceIndex]
+		target, targetOk := newIndexes[link.TargetIndex]
+		if sourceOk && targetOk {
+			link.SourceIndex, link.TargetIndex = source, target
+			prunedLinks = append(prunedLinks, link)
+		}
+	}
+	links = prunedLinks
+
+	totalNeuronCount := len(neurons)
+	hiddenCount := totalNeuronCount - firstHidden
+
+	// set biases of the output and hidden nodes encoded by CPPN
+	var biasList []float64
+	if nodes.biasEnabled() {
+		biasList = make([]float64, totalNeuronCount)
+		for i := firstOutput; i < totalNeuronCount; i++ {
+			biasList[i] = nodes.bias(neurons[i])
+		}
+	}
 
 	// build activations
 	activations := make([]neatmath.NodeActivationType, totalNeuronCount)
 	for i := 0; i < totalNeuronCount; i++ {
-		activations[i] = activationForNeuron(i)
+		activations[i] = activationForNeuron(neurons[i])
+	}
+
+	// build functions of neurons, only hidden nodes can be modulatory
+	functions := make([]NodeFunctionType, totalNeuronCount)
+	for i := 0; i < totalNeuronCount; i++ {
+		functions[i] = NodeFunctionStandard
+		if i >= firstHidden {
+			functions[i] = nodes.function(neurons[i])
+		}
+	}
+
+	// add nodes and edges of the pruned network to the graph
+	if graphBuilder != nil {
+		for i := firstInput; i < totalNeuronCount; i++ {
+			var position *PointF
+			nType := network.HiddenNeuron
+			if i < firstOutput {
+				nType = network.InputNeuron
+				position, err = es.Layout.NodePosition(i-firstInput, nType)
+			} else if i < firstHidden {
+				nType = network.OutputNeuron
+				position, err = es.Layout.NodePosition(i-firstOutput, nType)
+			} else {
+				position, err = es.Layout.NodePosition(neurons[i]-firstHidden, nType)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if _, err = addNodeToBuilder(graphBuilder, i, nType, functions[i], activations[i], position); err != nil {
+				return nil, err
+			}
+		}
+		for _, link := range links {
+			if _, err = addEdgeToBuilder(graphBuilder, link.SourceIndex, link.TargetIndex, link.Weight); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// create a fast network solver
@@ -234,27 +474,60 @@ func (es *EvolvableSubstrate) CreateNetworkSolver(cppn *network.Network, graphBu
 			len(links), totalNeuronCount, len(activations), options.LeoEnabled)
 		return nil, errors.New(message)
 	}
-	fmt.Printf("creating network solver: links [%d], nodes [%d]: input [%d], output [%d], hidden [%d]\n",
-		len(links), totalNeuronCount, es.Layout.InputCount(), es.Layout.OutputCount(), es.Layout.HiddenCount())
+	neat.DebugLog(fmt.Sprintf("creating network solver: links [%d], nodes [%d]: input [%d], output [%d], hidden [%d], pruned [%d]",
+		len(links), totalNeuronCount, es.Layout.InputCount(), es.Layout.OutputCount(), hiddenCount, es.prunedHiddenCount))
+
+	if options.PlasticityEnabled {
+		// create a plastic network solver with learning rules of the remaining links
+		linkRules := make([]HebbianRule, len(links))
+		for i, link := range links {
+			linkRules[i] = rules[link]
+		}
+		if !options.NeuromodulationEnabled {
+			functions = nil
+		}
+		solver, err := NewModulatedPlasticNetworkSolver(
+			biasCount, es.Layout.InputCount(), es.Layout.OutputCount(), totalNeuronCount,
+			activations, functions, links, linkRules, biasList, options.WeightRange)
+		if err != nil {
+			return nil, err
+		}
+		return solver, nil
+	}
 
 	solver := network.NewFastModularNetworkSolver(
-		0, es.Layout.InputCount(), es.Layout.OutputCount(), totalNeuronCount,
-		activations, links, nil, nil) // No BIAS
+		biasCount, es.Layout.InputCount(), es.Layout.OutputCount(), totalNeuronCount,
+		activations, links, biasList, nil)
 	return solver, nil
 }
 
-func (es *EvolvableSubstrate) addHiddenNode(qp *QuadPoint, firstHidden int, graphBuilder SubstrateGraphBuilder) (targetIndex int, err error) {
-	nodePoint := NewPointF(qp.X2, qp.Y2)
+// Adds the hidden node at the target position of provided quad point to the layout if it is not there yet. Returns the
+// index of the hidden node in the global indexes space, or -1 if the hidden node is not allowed by placement constraint
+// or the hidden nodes or CPPN queries limit is exceeded under truncate policy.
+func (es *EvolvableSubstrate) addHiddenNode(ctx context.Context, qp *QuadPoint, firstHidden int, nodes *nodeEncoder, placement PlacementConstraint, limits *resourceLimits) (targetIndex int, err error) {
+	nodePoint := &PointF{X: qp.X2, Y: qp.Y2, Z: qp.Z2}
+	if snapping, ok := es.Layout.(SnappingSubstrateLayout); ok {
+		nodePoint = snapping.SnapPosition(nodePoint)
+	}
 	targetIndex = es.Layout.IndexOfHidden(nodePoint)
 	if targetIndex == -1 {
+		if placement != nil && !placement.Allowed(*nodePoint) {
+			return -1, nil
+		}
+		if ok, err := limits.allowHiddenNode(es.Layout.HiddenCount()); !ok {
+			return -1, err
+		}
+		if ok, err := nodes.reserve(1); !ok {
+			return -1, err
+		}
 		// add a hidden node to the substrate layout
 		if targetIndex, err = es.Layout.AddHiddenNode(nodePoint); err != nil {
 			return -1, err
 		}
 
 		targetIndex += firstHidden // adjust index to the global indexes space
-		// add a node to the graph
-		if _, err = addNodeToBuilder(graphBuilder, targetIndex, network.HiddenNeuron, es.HiddenNodesActivation, nodePoint); err != nil {
+		// query CPPN for the node properties if appropriate
+		if err = nodes.query(ctx, []int{targetIndex}, []*PointF{nodePoint}); err != nil {
 			return -1, err
 		}
 	} else {
@@ -264,159 +537,116 @@ func (es *EvolvableSubstrate) addHiddenNode(qp *QuadPoint, firstHidden int, grap
 	return targetIndex, nil
 }
 
-// Divides and initialize the quadtree from provided coordinates of source (outgoing = true) or
-// target node (outgoing = false) at (a,b,c).
-// Returns quadtree, in which each quad-node at (x,y,z) stores CPPN activation level for its position. The initialized
-// quadtree is used in the PruningAndExtraction phase to generate the actual ANN connections.
-func (es *EvolvableSubstrate) quadTreeDivideAndInit(a, b, c float64, outgoing bool, options *eshyperneat.Options) (root *QuadNode, err error) {
-	root = NewQuadNode(0.0, 0.0, options.Width, options.Height, 1)
-
-	queue := list.New()
-	queue.PushBack(root)
-
-	for queue.Len() > 0 {
-		// de-queue
-		p := queue.Remove(queue.Front()).(*QuadNode)
-
-		// Divide into subregions and assign children to parent
-		p.Nodes = []*QuadNode{
-			NewQuadNode(p.X-p.Width/2.0, p.Y-p.Height/2.0, p.Width/2.0, p.Height/2.0, p.Level+1),
-			NewQuadNode(p.X-p.Width/2.0, p.Y+p.Height/2.0, p.Width/2.0, p.Height/2.0, p.Level+1),
-			NewQuadNode(p.X+p.Width/2.0, p.Y-p.Height/2.0, p.Width/2.0, p.Height/2.0, p.Level+1),
-			NewQuadNode(p.X+p.Width/2.0, p.Y+p.Height/2.0, p.Width/2.0, p.Height/2.0, p.Level+1),
+// Checks that the substrate region explored by the quadtree is valid and contains all input and output nodes of the
+// layout. The nodes outside the region result in error if StrictRegion option is set, or in warning otherwise, which
+// is logged only once for the same region and nodes.
+func (es *EvolvableSubstrate) validateExploredRegion(options *eshyperneat.Options) error {
+	region := options.ExploredRegion()
+	if err := region.Validate(); err != nil {
+		return err
+	}
+	outside := make([]string, 0)
+	for _, nType := range []network.NodeNeuronType{network.InputNeuron, network.OutputNeuron} {
+		count := es.Layout.InputCount()
+		if nType == network.OutputNeuron {
+			count = es.Layout.OutputCount()
 		}
-
-		for _, node := range p.Nodes {
-			if outgoing {
-				// Querying connection from input or hidden node (Outgoing connectivity pattern)
-				if node.CppnOut, err = es.queryCPPN(a, b, c, node.X, node.Y, node.Z); err != nil {
-					return nil, err
-				}
-			} else {
-				// Querying connection to the output node (Incoming connectivity pattern)
-				if node.CppnOut, err = es.queryCPPN(node.X, node.Y, node.Z, a, b, c); err != nil {
-					return nil, err
-				}
+		for i := 0; i < count; i++ {
+			position, err := es.Layout.NodePosition(i, nType)
+			if err != nil {
+				return err
 			}
-
-		}
-
-		// Divide until initial resolution or if variance is still high
-		if p.Level < options.InitialDepth || (p.Level < options.MaximalDepth && nodeVariance(p) > options.DivisionThreshold) {
-			for _, child := range p.Nodes {
-				queue.PushBack(child)
+			if !region.Contains(position.X, position.Y, position.Z) {
+				outside = append(outside, fmt.Sprintf("%s %d at %s", network.NeuronTypeName(nType), i, position))
 			}
 		}
 	}
-	return root, nil
+	if len(outside) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("the nodes are outside of the explored region %s: %s", region, strings.Join(outside, ", "))
+	if options.StrictRegion {
+		return errors.New(message)
+	}
+	warnRegionOnce(message)
+	return nil
 }
 
-// Decides what regions should have higher neuron density based on variation and express new neurons and connections into
-// these regions.
-// Receive coordinates of source (outgoing = true) or target node (outgoing = false) at (a, b) and initialized quadtree node.
-// Adds the connections that are in bands of the two-dimensional cross-section of the hypercube containing the source
-// or target node to the connection list and return a modified list.
-func (es *EvolvableSubstrate) pruneAndExpress(a, b, c float64, connections []*QuadPoint, node *QuadNode, outgoing bool, options *eshyperneat.Options) ([]*QuadPoint, error) {
-	// fast check
-	if len(node.Nodes) == 0 {
-		return connections, nil
-	}
-
-	// Traverse quadtree depth-first until the current node’s variance is smaller than the variance threshold or
-	// until the node has no children (which means that the variance is zero).
-	left, right, top, bottom := 0.0, 0.0, 0.0, 0.0
-	for _, quadNode := range node.Nodes {
-		childVariance := nodeVariance(quadNode)
-
-		if childVariance >= options.VarianceThreshold {
-			if conn, err := es.pruneAndExpress(a, b, c, nil, quadNode, outgoing, options); err != nil {
-				return nil, err
-			} else {
-				connections = append(connections, conn...)
-			}
-		} else if !options.LeoEnabled || (quadNode.Leo() > 0) {
-			// Band Pruning phase.
-			// If LEO is turned off, this should always happen.
-			// If it is not, it should only happen if the LEO output is greater than zero
-			if outgoing {
-				if l, err := es.queryCPPN(a, b, c, quadNode.X-node.Width, quadNode.Y, quadNode.Z); err != nil {
-					return nil, err
-				} else {
-					left = math.Abs(quadNode.Weight() - l[0])
-				}
-				if r, err := es.queryCPPN(a, b, c, quadNode.X+node.Width, quadNode.Y, quadNode.Z); err != nil {
-					return nil, err
-				} else {
-					right = math.Abs(quadNode.Weight() - r[0])
-				}
-				if t, err := es.queryCPPN(a, b, c, quadNode.X, quadNode.Y-node.Height, quadNode.Z); err != nil {
-					return nil, err
-				} else {
-					top = math.Abs(quadNode.Weight() - t[0])
-				}
-				if bot, err := es.queryCPPN(a, b, c, quadNode.X, quadNode.Y+node.Height, quadNode.Z); err != nil {
-					return nil, err
-				} else {
-					bottom = math.Abs(quadNode.Weight() - bot[0])
-				}
-			} else {
-				if l, err := es.queryCPPN(quadNode.X-node.Width, quadNode.Y, quadNode.Z, a, b, c); err != nil {
-					return nil, err
-				} else {
-					left = math.Abs(quadNode.Weight() - l[0])
-				}
-				if r, err := es.queryCPPN(quadNode.X+node.Width, quadNode.Y, quadNode.Z, a, b, c); err != nil {
-					return nil, err
-				} else {
-					right = math.Abs(quadNode.Weight() - r[0])
-				}
-				if t, err := es.queryCPPN(quadNode.X, quadNode.Y-node.Height, quadNode.Z, a, b, c); err != nil {
-					return nil, err
-				} else {
-					top = math.Abs(quadNode.Weight() - t[0])
-				}
-				if bot, err := es.queryCPPN(quadNode.X, quadNode.Y+node.Height, quadNode.Z, a, b, c); err != nil {
-					return nil, err
-				} else {
-					bottom = math.Abs(quadNode.Weight() - bot[0])
-				}
-			}
+// The warnings about the nodes outside the explored region already logged. The substrate is usually created for each
+// organism with the same layout and options, thus each warning is logged only once.
+var regionWarnings sync.Map
 
-			if math.Max(math.Min(top, bottom), math.Min(left, right)) > options.BandingThreshold {
-				// Create a new connection specified by QuadPoint(x1,y1,z1,x2,y2,z2,weight) in 4D hypercube
-				var conn *QuadPoint
-				if outgoing {
-					conn = NewQuadPoint(a, b, c, quadNode.X, quadNode.Y, quadNode.Z, quadNode)
-				} else {
-					conn = NewQuadPoint(quadNode.X, quadNode.Y, quadNode.Z, a, b, c, quadNode)
-				}
+// Logs the warning about the nodes outside the explored region if it was not logged before. Returns true if logged.
+func warnRegionOnce(message string) bool {
+	if _, logged := regionWarnings.LoadOrStore(message, true); logged {
+		return false
+	}
+	neat.WarnLog(message)
+	return true
+}
+
+// IterationStats Returns statistics of the hidden nodes discovery iterations collected during the last call of
+// CreateNetworkSolver. The iterations stop early if no new hidden nodes were discovered, thus the number of entries
+// can be less than the number of iterations defined by options.
+func (es *EvolvableSubstrate) IterationStats() []ESIterationStats {
+	return es.iterationStats
+}
+
+// PrunedHiddenCount Returns the number of discovered hidden nodes that were pruned from the network during the last
+// call of CreateNetworkSolver, because they are not on any path from the inputs to the outputs. It is always zero if
+// hidden nodes pruning is disabled by options.
+func (es *EvolvableSubstrate) PrunedHiddenCount() int {
+	return es.prunedHiddenCount
+}
 
-				connections = append(connections, conn)
+// ExceededResources Returns the resources which limits defined by options were exceeded during the last call of
+// CreateNetworkSolver. Under truncate policy, the non-empty list means that the created network is truncated.
+func (es *EvolvableSubstrate) ExceededResources() []ResourceType {
+	return es.exceededResources
+}
+
+// QueryCacheStats Returns statistics of the CPPN query cache collected during the last call of CreateNetworkSolver.
+// The statistics is empty if cache is disabled by options.
+func (es *EvolvableSubstrate) QueryCacheStats() QueryCacheStats {
+	return es.cacheStats
+}
+
+// Finds the hidden nodes that are on a path from any input node to any output node following provided links. Returns
+// the flags indexed by the index of the hidden node in the layout, which are true for connected hidden nodes.
+func connectedHiddenNodes(links []*network.FastNetworkLink, firstInput, firstOutput, firstHidden, hiddenCount int) []bool {
+	total := firstHidden + hiddenCount
+	outgoing := make([][]int, total)
+	incoming := make([][]int, total)
+	for _, link := range links {
+		outgoing[link.SourceIndex] = append(outgoing[link.SourceIndex], link.TargetIndex)
+		incoming[link.TargetIndex] = append(incoming[link.TargetIndex], link.SourceIndex)
+	}
+	// marks all nodes reachable from the nodes in range [first, last) following provided adjacency lists
+	reachable := func(first, last int, adjacent [][]int) []bool {
+		visited := make([]bool, total)
+		stack := make([]int, 0, total)
+		for i := first; i < last; i++ {
+			visited[i] = true
+			stack = append(stack, i)
+		}
+		for len(stack) > 0 {
+			node := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, next := range adjacent[node] {
+				if !visited[next] {
+					visited[next] = true
+					stack = append(stack, next)
+				}
 			}
 		}
+		return visited
 	}
+	fromInputs := reachable(firstInput, firstOutput, outgoing)
+	toOutputs := reachable(firstOutput, firstHidden, incoming)
 
-	return connections, nil
-}
-
-// Query CPPN associated with this substrate for specified Hypercube coordinate and returns value produced or error if
-// operation failed
-func (es *EvolvableSubstrate) queryCPPN(x1, y1, z1, x2, y2, z2 float64) ([]float64, error) {
-	offset := 0
-	if len(es.coords) == 7 {
-		// CPPN bias defined
-		offset = 1
-	}
-	es.coords[offset] = x1
-	es.coords[offset+1] = y1
-	es.coords[offset+2] = z1
-	es.coords[offset+3] = x2
-	es.coords[offset+4] = y2
-	es.coords[offset+5] = z2
-
-	if outs, err := queryCPPN(es.coords, es.cppn); err != nil {
-		return nil, errors.Wrap(err, "failed to query CPPN")
-	} else {
-		return outs, nil
+	connected := make([]bool, hiddenCount)
+	for i := range connected {
+		connected[i] = fromInputs[firstHidden+i] && toOutputs[firstHidden+i]
 	}
+	return connected
 }
@@ -0,0 +1,470 @@
+package cppn
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"math"
+	"sync"
+)
+
+// quadTreeExplorer explores the hypercube using quadtree to find the connectivity pattern of a particular substrate
+// node. Each explorer holds its own CPPN solver and cache, thus different explorers can be used concurrently.
+type quadTreeExplorer struct {
+	// The CPPN network solver to describe the geometry of substrate
+	cppn network.Solver
+	// The encoder of the hypercube coordinates into the CPPN inputs
+	encoder CoordinateEncoder
+	// The strategy to decide whether to express links
+	expression LinkExpressionStrategy
+	// The function to estimate the variance of CPPN outputs within the quadtree node region
+	variance VarianceFunction
+	// The cache of CPPN query results, nil if disabled
+	cache *queryCache
+	// The tracker of resources consumed, shared by all explorers
+	limits *resourceLimits
+}
+
+// Creates the list of explorers to be used for network solver creation. The first explorer uses provided CPPN, and all
+// others use their own copies of it. The number of explorers is defined by ExplorationWorkers option. All explorers
+// share provided tracker of the consumed resources. If the CPPN queries limit is set, the single explorer is created,
+// because the patterns explored before the limit is reached would depend on the order of concurrent queries.
+func newQuadTreeExplorers(cppn *network.Network, encoder CoordinateEncoder, expression LinkExpressionStrategy, variance VarianceFunction, limits *resourceLimits, options *eshyperneat.Options) ([]*quadTreeExplorer, error) {
+	if err := validateBandOptions(options); err != nil {
+		return nil, err
+	}
+	workers := options.ExplorationWorkers
+	if workers < 1 || options.MaxCppnQueries > 0 {
+		workers = 1
+	}
+	explorers := make([]*quadTreeExplorer, workers)
+	for i := range explorers {
+		explorer := &quadTreeExplorer{cppn: cppn, encoder: encoder, expression: expression, variance: variance, limits: limits}
+		if i > 0 {
+			if cppnCopy, err := copyNetwork(cppn); err != nil {
+				return nil, errors.Wrap(err, "failed to copy CPPN for exploration worker")
+			} else {
+				explorer.cppn = cppnCopy
+			}
+		}
+		if options.CppnCacheSize > 0 {
+			explorer.cache = newQueryCache(options.CppnCacheSize)
+		}
+		explorers[i] = explorer
+	}
+	return explorers, nil
+}
+
+// Explores the connectivity patterns of the substrate nodes at provided positions. The outgoing pattern is explored
+// if outgoing = true, and incoming otherwise. If more than one explorer provided, the nodes will be explored
+// concurrently, and the exploration of remaining nodes is cancelled after the first failure. The returned list holds
+// connections found for each node in the order of provided positions.
+func explorePatterns(ctx context.Context, explorers []*quadTreeExplorer, positions []*PointF, outgoing bool, options *eshyperneat.Options) ([][]*QuadPoint, error) {
+	patterns := make([][]*QuadPoint, len(positions))
+	if len(explorers) == 1 || len(positions) < 2 {
+		for i, position := range positions {
+			if qPoints, err := explorers[0].explore(ctx, position, outgoing, options); err != nil {
+				return nil, err
+			} else {
+				patterns[i] = qPoints
+			}
+		}
+		return patterns, nil
+	}
+
+	errs := make([]error, len(positions))
+	jobs := make(chan int, len(positions))
+	for i := range positions {
+		jobs <- i
+	}
+	close(jobs)
+
+	// the context to stop other workers when any of them failed
+	workersCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, explorer := range explorers {
+		wg.Add(1)
+		go func(explorer *quadTreeExplorer) {
+			defer wg.Done()
+			for i := range jobs {
+				if patterns[i], errs[i] = explorer.explore(workersCtx, positions[i], outgoing, options); errs[i] != nil {
+					cancel()
+				}
+			}
+		}(explorer)
+	}
+	wg.Wait()
+
+	if err := firstExplorationError(ctx, errs); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// Returns the first error of concurrent exploration in the order of explored nodes, skipping the errors caused by the
+// cancellation of other workers after the failure, unless the provided parent context is done.
+func firstExplorationError(ctx context.Context, errs []error) error {
+	for _, err := range errs {
+		if err != nil && (ctx.Err() != nil || !errors.Is(err, context.Canceled)) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Explores the connectivity pattern of the substrate node at a given position. Returns the list of connections found.
+// If the CPPN queries limit is exceeded under truncate policy, no connections are returned.
+func (e *quadTreeExplorer) explore(ctx context.Context, position *PointF, outgoing bool, options *eshyperneat.Options) ([]*QuadPoint, error) {
+	root, err := e.quadTreeDivideAndInit(ctx, position.X, position.Y, position.Z, outgoing, options)
+	if err == nil {
+		qPoints := make([]*QuadPoint, 0)
+		if qPoints, err = e.pruneAndExpress(ctx, position.X, position.Y, position.Z, qPoints, root, outgoing, options); err == nil {
+			return qPoints, nil
+		}
+	}
+	if errors.Is(err, errExplorationTruncated) {
+		// the connectivity pattern which can not be explored within the limit is dropped
+		return nil, nil
+	}
+	return nil, err
+}
+
+// Returns the statistics of the cache associated with this explorer
+func (e *quadTreeExplorer) cacheStats() QueryCacheStats {
+	if e.cache == nil {
+		return QueryCacheStats{}
+	}
+	return e.cache.stats
+}
+
+// Divides and initialize the quadtree from provided coordinates of source (outgoing = true) or
+// target node (outgoing = false) at (a,b,c). The root of the tree covers the substrate region defined by options. If the
+// region is the volume, the octree is built.
+// Returns quadtree, in which each quad-node at (x,y,z) stores CPPN activation level for its position. The initialized
+// quadtree is used in the PruningAndExtraction phase to generate the actual ANN connections.
+func (e *quadTreeExplorer) quadTreeDivideAndInit(ctx context.Context, a, b, c float64, outgoing bool, options *eshyperneat.Options) (root *QuadNode, err error) {
+	region := options.ExploredRegion()
+	x, y, z := (region.MinX+region.MaxX)/2.0, (region.MinY+region.MaxY)/2.0, (region.MinZ+region.MaxZ)/2.0
+	width, height, depth := (region.MaxX-region.MinX)/2.0, (region.MaxY-region.MinY)/2.0, (region.MaxZ-region.MinZ)/2.0
+	if region.IsVolume() {
+		root = NewOctNode(x, y, z, width, height, depth, 1)
+	} else {
+		root = NewQuadNodeZ(x, y, z, width, height, 1)
+	}
+
+	// the quadtree is divided level by level, and all nodes of the level are queried with CPPN in one batch
+	level := []*QuadNode{root}
+	for len(level) > 0 {
+		children := make([]*QuadNode, 0, len(level)*8)
+		for _, p := range level {
+			// Divide into subregions and assign children to parent
+			p.Nodes = subdivide(p)
+			children = append(children, p.Nodes...)
+		}
+
+		coordinates := make([][]float64, len(children))
+		for i, node := range children {
+			if outgoing {
+				// Querying connection from input or hidden node (Outgoing connectivity pattern)
+				coordinates[i] = e.hypercubeCoordinates(a, b, c, node.X, node.Y, node.Z)
+			} else {
+				// Querying connection to the output node (Incoming connectivity pattern)
+				coordinates[i] = e.hypercubeCoordinates(node.X, node.Y, node.Z, a, b, c)
+			}
+		}
+		outputs, err := e.queryCPPNBatch(ctx, coordinates)
+		if err != nil {
+			return nil, err
+		}
+		for i, node := range children {
+			node.CppnOut = outputs[i]
+		}
+
+		// Divide until initial resolution or if variance is still high
+		next := make([]*QuadNode, 0, len(children))
+		for _, p := range level {
+			if p.Level < options.InitialDepth || (p.Level < options.MaximalDepth && e.variance(p) > options.DivisionThreshold) {
+				// the division of children of p produces nodes two levels below it
+				if ok, err := e.limits.allowDepth(p.Level + 2); err != nil {
+					return nil, err
+				} else if !ok {
+					continue
+				}
+				next = append(next, p.Nodes...)
+			}
+		}
+		level = next
+	}
+	return root, nil
+}
+
+// Decides what regions should have higher neuron density based on variation and express new neurons and connections into
+// these regions.
+// Receive coordinates of source (outgoing = true) or target node (outgoing = false) at (a, b) and initialized quadtree node.
+// Adds the connections that are in bands of the two-dimensional cross-section of the hypercube containing the source
+// or target node to the connection list and return a modified list.
+func (e *quadTreeExplorer) pruneAndExpress(ctx context.Context, a, b, c float64, connections []*QuadPoint, node *QuadNode, outgoing bool, options *eshyperneat.Options) ([]*QuadPoint, error) {
+	// fast check
+	if len(node.Nodes) == 0 {
+		return connections, nil
+	}
+
+	// Band Pruning phase.
+	// Find child nodes to be checked for banding and query CPPN for all their neighbours at once.
+	// If link expression does not depend on LEO, this should always happen.
+	// If it does, it should only happen if the link at the child node would be expressed
+	probes := make([][]*PointF, len(node.Nodes))
+	coordinates := make([][]float64, 0, len(node.Nodes)*6)
+	for i, quadNode := range node.Nodes {
+		if e.variance(quadNode) >= options.VarianceThreshold {
+			continue
+		}
+		if !e.expression.UsesLeo() || e.expressed(a, b, c, quadNode, outgoing) {
+			probes[i] = neighbours(quadNode, node, options)
+			for _, n := range probes[i] {
+				if n == nil {
+					// ignored neighbour
+					continue
+				}
+				if outgoing {
+					coordinates = append(coordinates, e.hypercubeCoordinates(a, b, c, n.X, n.Y, n.Z))
+				} else {
+					coordinates = append(coordinates, e.hypercubeCoordinates(n.X, n.Y, n.Z, a, b, c))
+				}
+			}
+		}
+	}
+	outputs, err := e.queryCPPNBatch(ctx, coordinates)
+	if err != nil {
+		return nil, err
+	}
+
+	// Traverse quadtree depth-first until the current node’s variance is smaller than the variance threshold or
+	// until the node has no children (which means that the variance is zero).
+	next := 0
+	for i, quadNode := range node.Nodes {
+		if e.variance(quadNode) >= options.VarianceThreshold {
+			if conn, err := e.pruneAndExpress(ctx, a, b, c, nil, quadNode, outgoing, options); err != nil {
+				return nil, err
+			} else {
+				connections = append(connections, conn...)
+			}
+		} else if probes[i] != nil {
+			var band float64
+			band, next = bandValue(quadNode.Weight(), probes[i], outputs, next)
+			if band > options.BandingThreshold {
+				// Create a new connection specified by QuadPoint(x1,y1,z1,x2,y2,z2,weight) in 4D hypercube
+				var conn *QuadPoint
+				if outgoing {
+					conn = NewQuadPoint(a, b, c, quadNode.X, quadNode.Y, quadNode.Z, quadNode)
+				} else {
+					conn = NewQuadPoint(quadNode.X, quadNode.Y, quadNode.Z, a, b, c, quadNode)
+				}
+
+				connections = append(connections, conn)
+			}
+		}
+	}
+
+	return connections, nil
+}
+
+// Divides the region of the given node into four equal subregions, or eight if the node is the octree node
+func subdivide(p *QuadNode) []*QuadNode {
+	if !p.IsOctant() {
+		return []*QuadNode{
+			NewQuadNodeZ(p.X-p.Width/2.0, p.Y-p.Height/2.0, p.Z, p.Width/2.0, p.Height/2.0, p.Level+1),
+			NewQuadNodeZ(p.X-p.Width/2.0, p.Y+p.Height/2.0, p.Z, p.Width/2.0, p.Height/2.0, p.Level+1),
+			NewQuadNodeZ(p.X+p.Width/2.0, p.Y-p.Height/2.0, p.Z, p.Width/2.0, p.Height/2.0, p.Level+1),
+			NewQuadNodeZ(p.X+p.Width/2.0, p.Y+p.Height/2.0, p.Z, p.Width/2.0, p.Height/2.0, p.Level+1),
+		}
+	}
+	nodes := make([]*QuadNode, 0, 8)
+	for _, dz := range []float64{-p.Depth / 2.0, p.Depth / 2.0} {
+		for _, dx := range []float64{-p.Width / 2.0, p.Width / 2.0} {
+			for _, dy := range []float64{-p.Height / 2.0, p.Height / 2.0} {
+				nodes = append(nodes, NewOctNode(p.X+dx, p.Y+dy, p.Z+dz, p.Width/2.0, p.Height/2.0, p.Depth/2.0, p.Level+1))
+			}
+		}
+	}
+	return nodes
+}
+
+// The directions to the pairs of opposite neighbours probed for banding
+var (
+	planarAxes      = [][3]float64{{1, 0, 0}, {0, 1, 0}}
+	planarDiagonals = [][3]float64{{1, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, -1, 0}}
+	volumeAxes      = [][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	volumeDiagonals = [][3]float64{
+		{1, 0, 0}, {0, 1, 0}, {0, 0, 1},
+		{1, 1, 0}, {1, -1, 0}, {1, 0, 1}, {1, 0, -1}, {0, 1, 1}, {0, 1, -1},
+		{1, 1, 1}, {1, 1, -1}, {1, -1, 1}, {1, -1, -1},
+	}
+)
+
+// Returns the neighbours of the given child node of the parent node to be probed for banding as the pairs of opposite
+// points in order: left and right, top and bottom, front and back for the octree nodes, followed by the diagonal pairs
+// if defined by options. The neighbour outside the substrate bounds is nil if it should be ignored.
+func neighbours(child, parent *QuadNode, options *eshyperneat.Options) []*PointF {
+	dx, dy, dz := parent.Width, parent.Height, parent.Depth
+	if options.BandProbeDistance == eshyperneat.BandProbeDistanceChild {
+		dx, dy, dz = child.Width, child.Height, child.Depth
+	}
+	directions := planarAxes
+	diagonal := options.BandNeighbourhood == eshyperneat.BandNeighbourhoodDiagonal
+	if child.IsOctant() && diagonal {
+		directions = volumeDiagonals
+	} else if child.IsOctant() {
+		directions = volumeAxes
+	} else if diagonal {
+		directions = planarDiagonals
+	}
+
+	low, high := substrateBounds(options)
+	points := make([]*PointF, 0, len(directions)*2)
+	for _, d := range directions {
+		for _, sign := range []float64{-1, 1} {
+			point := &PointF{X: child.X + sign*d[0]*dx, Y: child.Y + sign*d[1]*dy, Z: child.Z + sign*d[2]*dz}
+			if point.X < low.X || point.X > high.X || point.Y < low.Y || point.Y > high.Y ||
+				point.Z < low.Z || point.Z > high.Z {
+				switch options.BandBoundary {
+				case eshyperneat.BandBoundaryClamp:
+					point.X = math.Max(low.X, math.Min(point.X, high.X))
+					point.Y = math.Max(low.Y, math.Min(point.Y, high.Y))
+					point.Z = math.Max(low.Z, math.Min(point.Z, high.Z))
+				case eshyperneat.BandBoundaryIgnore:
+					point = nil
+				}
+			}
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// Returns the band value of the node with given CPPN weight output, which is the maximum over the pairs of opposite
+// neighbours of the minimal difference between the weight of the node and the weight of a neighbour. The CPPN outputs
+// of the probed neighbours are read from provided list starting at the next index. Returns the band value and the index
+// of the first unread output.
+func bandValue(weight float64, points []*PointF, outputs [][]float64, next int) (float64, int) {
+	band := 0.0
+	for j := 0; j+1 < len(points); j += 2 {
+		pair, found := math.Inf(1), false
+		for _, point := range points[j : j+2] {
+			if point == nil {
+				// ignored neighbour
+				continue
+			}
+			pair, found = math.Min(pair, math.Abs(weight-outputs[next][0])), true
+			next++
+		}
+		if found {
+			band = math.Max(band, pair)
+		}
+	}
+	return band, next
+}
+
+// Returns the lower and upper corners of the substrate region explored by the quadtree
+func substrateBounds(options *eshyperneat.Options) (low, high PointF) {
+	region := options.ExploredRegion()
+	return PointF{X: region.MinX, Y: region.MinY, Z: region.MinZ}, PointF{X: region.MaxX, Y: region.MaxY, Z: region.MaxZ}
+}
+
+// Checks that band pruning options are supported
+func validateBandOptions(options *eshyperneat.Options) error {
+	switch options.BandNeighbourhood {
+	case "", eshyperneat.BandNeighbourhoodAxisAligned, eshyperneat.BandNeighbourhoodDiagonal:
+	default:
+		return errors.Errorf("unsupported band neighbourhood: %s", options.BandNeighbourhood)
+	}
+	switch options.BandProbeDistance {
+	case "", eshyperneat.BandProbeDistanceNode, eshyperneat.BandProbeDistanceChild:
+	default:
+		return errors.Errorf("unsupported band probe distance: %s", options.BandProbeDistance)
+	}
+	switch options.BandBoundary {
+	case "", eshyperneat.BandBoundaryQuery, eshyperneat.BandBoundaryClamp, eshyperneat.BandBoundaryIgnore:
+	default:
+		return errors.Errorf("unsupported band boundary: %s", options.BandBoundary)
+	}
+	return nil
+}
+
+// Returns true if the link between the node at (a, b, c) and the given quadtree node would be expressed
+func (e *quadTreeExplorer) expressed(a, b, c float64, node *QuadNode, outgoing bool) bool {
+	source, target := PointF{X: a, Y: b, Z: c}, PointF{X: node.X, Y: node.Y, Z: node.Z}
+	if !outgoing {
+		source, target = target, source
+	}
+	_, ok := e.expression.ExpressLink(node.Weight(), node.Leo(), source, target)
+	return ok
+}
+
+// Creates the hypercube point with specified coordinates of the source and target
+func (e *quadTreeExplorer) hypercubeCoordinates(x1, y1, z1, x2, y2, z2 float64) []float64 {
+	return []float64{x1, y1, z1, x2, y2, z2}
+}
+
+// Query CPPN associated with this substrate for all specified hypercube points and returns values produced or error if
+// operation failed. If the cache is enabled, only points not found in the cache will be queried. If the context is
+// cancelled or its deadline exceeded, the context error is returned.
+func (e *quadTreeExplorer) queryCPPNBatch(ctx context.Context, coordinates [][]float64) ([][]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// collect points not found in the cache
+	outs := make([][]float64, len(coordinates))
+	missed := make([]int, 0, len(coordinates))
+	missedCoordinates := make([][]float64, 0, len(coordinates))
+	for i, coords := range coordinates {
+		if e.cache != nil {
+			if cached, ok := e.cache.get(coords); ok {
+				outs[i] = cached
+				continue
+			}
+		}
+		missed = append(missed, i)
+		missedCoordinates = append(missedCoordinates, coords)
+	}
+	if err := e.reserveCppnQueries(len(missedCoordinates)); err != nil {
+		return nil, err
+	}
+	missedOuts, err := QueryCPPNBatchContext(ctx, e.encode(missedCoordinates), e.cppn)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, errors.Wrap(err, "failed to query CPPN")
+	}
+	for j, i := range missed {
+		outs[i] = missedOuts[j]
+		if e.cache != nil {
+			e.cache.put(coordinates[i], missedOuts[j])
+		}
+	}
+	return outs, nil
+}
+
+// Reserves the given number of CPPN queries within the limit. Returns errExplorationTruncated if the limit exceeded under
+// truncate policy, or the resource limit error otherwise.
+func (e *quadTreeExplorer) reserveCppnQueries(count int) error {
+	if ok, err := e.limits.reserveCppnQueries(count); err != nil {
+		return err
+	} else if !ok {
+		return errExplorationTruncated
+	}
+	return nil
+}
+
+// Encodes provided hypercube points into the CPPN inputs
+func (e *quadTreeExplorer) encode(coordinates [][]float64) [][]float64 {
+	inputs := make([][]float64, len(coordinates))
+	for i, c := range coordinates {
+		inputs[i] = encodeCoordinates(e.encoder, PointF{X: c[0], Y: c[1], Z: c[2]}, PointF{X: c[3], Y: c[4], Z: c[5]})
+	}
+	return inputs
+}
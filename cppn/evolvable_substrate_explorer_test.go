@@ -0,0 +1,125 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"testing"
+)
+
+func TestSubdivide(t *testing.T) {
+	quad := NewQuadNode(0.0, 0.0, 1.0, 2.0, 1)
+	children := subdivide(quad)
+	require.Len(t, children, 4)
+	for _, child := range children {
+		assert.False(t, child.IsOctant())
+		assert.Equal(t, 0.5, child.Width)
+		assert.Equal(t, 1.0, child.Height)
+		assert.Equal(t, 2, child.Level)
+	}
+
+	oct := NewOctNode(0.0, 0.0, 0.0, 1.0, 1.0, 2.0, 1)
+	children = subdivide(oct)
+	require.Len(t, children, 8)
+	centers := make(map[PointF]bool)
+	for _, child := range children {
+		assert.True(t, child.IsOctant())
+		assert.Equal(t, 1.0, child.Depth)
+		assert.Equal(t, 2, child.Level)
+		centers[PointF{X: child.X, Y: child.Y, Z: child.Z}] = true
+	}
+	for _, x := range []float64{-0.5, 0.5} {
+		for _, y := range []float64{-0.5, 0.5} {
+			for _, z := range []float64{-1.0, 1.0} {
+				assert.True(t, centers[PointF{X: x, Y: y, Z: z}], "octant not found at: (%f, %f, %f)", x, y, z)
+			}
+		}
+	}
+}
+
+func TestNeighbours(t *testing.T) {
+	options := &eshyperneat.Options{Width: 1.0, Height: 1.0, Depth: 1.0}
+	quad := NewQuadNode(0.0, 0.0, 1.0, 1.0, 1)
+	quad.Nodes = subdivide(quad)
+	expected := []*PointF{{X: -1.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: -0.5, Y: -1.5}, {X: -0.5, Y: 0.5}}
+	assert.Equal(t, expected, neighbours(quad.Nodes[0], quad, options))
+
+	oct := NewOctNode(0.0, 0.0, 0.0, 1.0, 1.0, 1.0, 1)
+	oct.Nodes = subdivide(oct)
+	expected = []*PointF{
+		{X: -1.5, Y: -0.5, Z: -0.5}, {X: 0.5, Y: -0.5, Z: -0.5},
+		{X: -0.5, Y: -1.5, Z: -0.5}, {X: -0.5, Y: 0.5, Z: -0.5},
+		{X: -0.5, Y: -0.5, Z: -1.5}, {X: -0.5, Y: -0.5, Z: 0.5},
+	}
+	assert.Equal(t, expected, neighbours(oct.Nodes[0], oct, options))
+}
+
+func TestNeighbours_Options(t *testing.T) {
+	quad := NewQuadNode(0.0, 0.0, 1.0, 1.0, 1)
+	quad.Nodes = subdivide(quad)
+	child := quad.Nodes[0] // at (-0.5, -0.5)
+
+	testCases := []struct {
+		name     string
+		options  *eshyperneat.Options
+		expected []*PointF
+	}{
+		{
+			name:    "diagonal",
+			options: &eshyperneat.Options{BandNeighbourhood: eshyperneat.BandNeighbourhoodDiagonal},
+			expected: []*PointF{
+				{X: -1.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: -0.5, Y: -1.5}, {X: -0.5, Y: 0.5},
+				{X: -1.5, Y: -1.5}, {X: 0.5, Y: 0.5}, {X: -1.5, Y: 0.5}, {X: 0.5, Y: -1.5},
+			},
+		},
+		{
+			name:     "child distance",
+			options:  &eshyperneat.Options{BandProbeDistance: eshyperneat.BandProbeDistanceChild},
+			expected: []*PointF{{X: -1.0, Y: -0.5}, {X: 0.0, Y: -0.5}, {X: -0.5, Y: -1.0}, {X: -0.5, Y: 0.0}},
+		},
+		{
+			name:     "clamp",
+			options:  &eshyperneat.Options{Width: 1.0, Height: 1.0, BandBoundary: eshyperneat.BandBoundaryClamp},
+			expected: []*PointF{{X: -1.0, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: -0.5, Y: -1.0}, {X: -0.5, Y: 0.5}},
+		},
+		{
+			name:     "ignore",
+			options:  &eshyperneat.Options{Width: 1.0, Height: 1.0, BandBoundary: eshyperneat.BandBoundaryIgnore},
+			expected: []*PointF{nil, {X: 0.5, Y: -0.5}, nil, {X: -0.5, Y: 0.5}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, neighbours(child, quad, tc.options))
+		})
+	}
+
+	// all 26 neighbours of the octree node
+	oct := NewOctNode(0.0, 0.0, 0.0, 1.0, 1.0, 1.0, 1)
+	oct.Nodes = subdivide(oct)
+	points := neighbours(oct.Nodes[0], oct, &eshyperneat.Options{BandNeighbourhood: eshyperneat.BandNeighbourhoodDiagonal})
+	unique := make(map[PointF]bool)
+	for _, point := range points {
+		unique[*point] = true
+	}
+	assert.Len(t, unique, 26)
+}
+
+func TestBandValue(t *testing.T) {
+	outputs := [][]float64{{0.1}, {0.9}, {0.6}, {0.8}, {0.0}}
+	points := []*PointF{{}, {}, {}, {}, nil, nil, {}, nil}
+	// pairs: min(0.4, 0.4), min(0.1, 0.3), skipped, 0.5
+	band, next := bandValue(0.5, points, outputs, 0)
+	assert.InDelta(t, 0.5, band, 1e-15)
+	assert.Equal(t, 5, next)
+}
+
+func TestValidateBandOptions(t *testing.T) {
+	assert.NoError(t, validateBandOptions(&eshyperneat.Options{}))
+	assert.EqualError(t, validateBandOptions(&eshyperneat.Options{BandNeighbourhood: "unknown"}),
+		"unsupported band neighbourhood: unknown")
+	assert.EqualError(t, validateBandOptions(&eshyperneat.Options{BandProbeDistance: "unknown"}),
+		"unsupported band probe distance: unknown")
+	assert.EqualError(t, validateBandOptions(&eshyperneat.Options{BandBoundary: "unknown"}),
+		"unsupported band boundary: unknown")
+}
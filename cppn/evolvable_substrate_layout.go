@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/yaricom/goNEAT/v4/neat/network"
+	"math"
 )
 
 // EvolvableSubstrateLayout Defines the layout of neurons in the substrate
@@ -25,26 +26,91 @@ type EvolvableSubstrateLayout interface {
 	OutputCount() int
 }
 
+// SnappingSubstrateLayout Defines the optional capability of the EvolvableSubstrateLayout to adjust positions of hidden
+// nodes, e.g., to merge nearby hidden nodes. The evolvable substrate uses the snapped position of the hidden node if
+// its layout implements this interface, or the position discovered by the quadtree otherwise.
+type SnappingSubstrateLayout interface {
+	// SnapPosition Returns the canonical position of the hidden node at a specified position, i.e., the position of
+	// already known hidden node which is merged with the specified position, or the specified position adjusted by the
+	// layout rules
+	SnapPosition(position *PointF) *PointF
+}
+
+// ResettableSubstrateLayout Defines the optional capability of the EvolvableSubstrateLayout to forget the hidden nodes
+// discovered for the previous CPPN. The evolvable substrate resets its layout before creating the network solver if
+// the layout implements this interface, or keeps all hidden nodes discovered so far otherwise.
+type ResettableSubstrateLayout interface {
+	// Reset Removes the hidden nodes discovered by the evolvable substrate
+	Reset()
+}
+
 // NewMappedEvolvableSubstrateLayout Creates new instance with given input and output neurons count
 func NewMappedEvolvableSubstrateLayout(inputCount, outputCount int) (*MappedEvolvableSubstrateLayout, error) {
-	if inputCount == 0 {
+	return NewMappedEvolvableSubstrateLayoutWithTolerance(inputCount, outputCount, 0, 0)
+}
+
+// NewMappedEvolvableSubstrateLayoutWithTolerance Creates new instance with given input and output neurons count, which
+// snaps hidden nodes to the grid with a given step and merges hidden nodes within epsilon distance from each other.
+// The hidden node positions are snapped to the nearest grid point before any other check. The position within epsilon
+// distance from known hidden nodes is merged with the nearest of them, and if several are at the same distance, with
+// the one added first. Zero step or epsilon turns off snapping or merging respectively.
+func NewMappedEvolvableSubstrateLayoutWithTolerance(inputCount, outputCount int, snapStep, epsilon float64) (*MappedEvolvableSubstrateLayout, error) {
+	return NewSheetEvolvableSubstrateLayoutWithTolerance(NewLineSheet(inputCount, -1.0), NewLineSheet(outputCount, 1.0), snapStep, epsilon)
+}
+
+// NewSheetEvolvableSubstrateLayout Creates new instance with input and output nodes arranged in the given
+// two-dimensional sheets
+func NewSheetEvolvableSubstrateLayout(input, output Sheet) (*MappedEvolvableSubstrateLayout, error) {
+	return NewSheetEvolvableSubstrateLayoutWithTolerance(input, output, 0, 0)
+}
+
+// NewSheetEvolvableSubstrateLayoutWithTolerance Creates new instance with input and output nodes arranged in the given
+// two-dimensional sheets, which snaps and merges hidden nodes as NewMappedEvolvableSubstrateLayoutWithTolerance.
+func NewSheetEvolvableSubstrateLayoutWithTolerance(input, output Sheet, snapStep, epsilon float64) (*MappedEvolvableSubstrateLayout, error) {
+	if snapStep < 0 {
+		return nil, errors.Errorf("the snapping grid step can not be negative: %f", snapStep)
+	}
+	if epsilon < 0 {
+		return nil, errors.Errorf("the merging epsilon can not be negative: %f", epsilon)
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid input sheet")
+	}
+	if err := output.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid output sheet")
+	}
+	return newMappedEvolvableSubstrateLayout(input, output, snapStep, epsilon)
+}
+
+// Creates new instance with input and output nodes placed by given placements, which snaps and merges hidden nodes
+func newMappedEvolvableSubstrateLayout(inputs, outputs nodePlacement, snapStep, epsilon float64) (*MappedEvolvableSubstrateLayout, error) {
+	if inputs.Count() == 0 {
 		return nil, errors.New("the number of input neurons can not be ZERO")
 	}
-	if outputCount == 0 {
+	if outputs.Count() == 0 {
 		return nil, errors.New("the number of output neurons can not be ZERO")
 	}
 
 	l := &MappedEvolvableSubstrateLayout{
-		hNodesMap:   make(map[PointF]int),
-		hNodesList:  make([]*PointF, 0),
-		inputCount:  inputCount,
-		outputCount: outputCount,
-		inputDelta:  2.0 / float64(inputCount),
-		outputDelta: 2.0 / float64(outputCount),
+		hNodesMap:  make(map[PointF]int),
+		hNodesList: make([]*PointF, 0),
+		hNodesGrid: make(map[gridCell][]int),
+		snapStep:   snapStep,
+		epsilon:    epsilon,
+		inputs:     inputs,
+		outputs:    outputs,
 	}
 	return l, nil
 }
 
+// nodePlacement Defines the positions of the layout nodes of the same type, e.g., the Sheet
+type nodePlacement interface {
+	// Count Returns the number of nodes
+	Count() int
+	// Position Returns coordinates of the node with specified index [0; count)
+	Position(index int) (*PointF, error)
+}
+
 // MappedEvolvableSubstrateLayout the EvolvableSubstrateLayout implementation using a map for binding between a hidden
 // node and its index
 type MappedEvolvableSubstrateLayout struct {
@@ -52,55 +118,42 @@ type MappedEvolvableSubstrateLayout struct {
 	hNodesMap map[PointF]int
 	// The list of all known hidden nodes in a specific order
 	hNodesList []*PointF
+	// The grid hash of hidden node indexes with cells of the epsilon size for fast search of the nearby nodes
+	hNodesGrid map[gridCell][]int
 
-	// The number of input nodes encoded in this substrate
-	inputCount int
-	// The number of output nodes encoded in this substrate
-	outputCount int
+	// The step of the grid to snap hidden nodes to, zero if snapping is disabled
+	snapStep float64
+	// The distance within which hidden nodes are merged, zero if merging is disabled
+	epsilon float64
 
-	// The input coordinates increment
-	inputDelta float64
-	// The output coordinates increment
-	outputDelta float64
+	// The placement of input nodes encoded in this substrate
+	inputs nodePlacement
+	// The placement of output nodes encoded in this substrate
+	outputs nodePlacement
 }
 
 func (m *MappedEvolvableSubstrateLayout) NodePosition(index int, nType network.NodeNeuronType) (*PointF, error) {
 	if index < 0 {
 		return nil, errors.New("neuron index can not be negative")
 	}
-	point := PointF{X: 0.0, Y: 0.0}
-	delta := 0.0
-	count := 0
 	switch nType {
 	case network.BiasNeuron:
 		return nil, errors.New("the BIAS neurons is not supported by Evolvable Substrate")
 
 	case network.HiddenNeuron:
-		count = len(m.hNodesList)
+		if index >= len(m.hNodesList) {
+			return nil, errors.New("neuron index is out of range")
+		}
+		// return stored hidden neuron position
+		return m.hNodesList[index], nil
 
 	case network.InputNeuron:
-		delta = m.inputDelta
-		count = m.inputCount
-		point.Y = -1.0
+		return m.inputs.Position(index)
 
 	case network.OutputNeuron:
-		delta = m.outputDelta
-		count = m.outputCount
-		point.Y = 1.0
-	}
-
-	if index >= count {
-		return nil, errors.New("neuron index is out of range")
-	} else if nType == network.HiddenNeuron {
-		// return stored hidden neuron position
-		return m.hNodesList[index], nil
+		return m.outputs.Position(index)
 	}
-
-	// calculate X position
-	point.X = -1.0 + delta/2.0 // the initial position with a half-delta shift
-	point.X += float64(index) * delta
-
-	return &point, nil
+	return nil, errors.New("neuron index is out of range")
 }
 
 func (m *MappedEvolvableSubstrateLayout) AddHiddenNode(position *PointF) (int, error) {
@@ -108,28 +161,110 @@ func (m *MappedEvolvableSubstrateLayout) AddHiddenNode(position *PointF) (int, e
 	if m.IndexOfHidden(position) != -1 {
 		return -1, errors.Errorf("hidden node already exists at the position: %s", position)
 	}
+	position = m.snap(position)
 	// add to the list and map it
 	m.hNodesList = append(m.hNodesList, position)
 	index := len(m.hNodesList) - 1
 	m.hNodesMap[*position] = index
+	if m.epsilon > 0 {
+		cell := m.cellOf(position)
+		m.hNodesGrid[cell] = append(m.hNodesGrid[cell], index)
+	}
 	return index, nil
 }
 
+// Removes the hidden nodes added after the given number of first hidden nodes
+func (m *MappedEvolvableSubstrateLayout) truncateHidden(count int) {
+	if count >= len(m.hNodesList) {
+		return
+	}
+	for _, position := range m.hNodesList[count:] {
+		delete(m.hNodesMap, *position)
+	}
+	m.hNodesList = m.hNodesList[:count]
+	if m.epsilon > 0 {
+		m.hNodesGrid = make(map[gridCell][]int)
+		for index, position := range m.hNodesList {
+			cell := m.cellOf(position)
+			m.hNodesGrid[cell] = append(m.hNodesGrid[cell], index)
+		}
+	}
+}
+
 func (m *MappedEvolvableSubstrateLayout) IndexOfHidden(position *PointF) int {
+	position = m.snap(position)
 	if index, ok := m.hNodesMap[*position]; ok {
 		return index
+	} else if m.epsilon > 0 {
+		return m.nearestHidden(position)
 	} else {
 		return -1
 	}
 }
 
+func (m *MappedEvolvableSubstrateLayout) SnapPosition(position *PointF) *PointF {
+	if index := m.IndexOfHidden(position); index != -1 {
+		return m.hNodesList[index]
+	}
+	return m.snap(position)
+}
+
+// Returns the position snapped to the nearest point of the grid if snapping is enabled, or the given position otherwise
+func (m *MappedEvolvableSubstrateLayout) snap(position *PointF) *PointF {
+	if m.snapStep <= 0 {
+		return position
+	}
+	return &PointF{
+		X: math.Round(position.X/m.snapStep) * m.snapStep,
+		Y: math.Round(position.Y/m.snapStep) * m.snapStep,
+		Z: math.Round(position.Z/m.snapStep) * m.snapStep,
+	}
+}
+
+// Returns the index of the nearest hidden node within epsilon distance from the given position, or -1 if not found.
+// If several nodes are at the same distance, the one with the lowest index is returned.
+func (m *MappedEvolvableSubstrateLayout) nearestHidden(position *PointF) int {
+	nearest, nearestDistance := -1, math.Inf(1)
+	cell := m.cellOf(position)
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dz := int64(-1); dz <= 1; dz++ {
+				for _, index := range m.hNodesGrid[gridCell{cell[0] + dx, cell[1] + dy, cell[2] + dz}] {
+					node := m.hNodesList[index]
+					distance := math.Sqrt(math.Pow(node.X-position.X, 2) + math.Pow(node.Y-position.Y, 2) +
+						math.Pow(node.Z-position.Z, 2))
+					if distance > m.epsilon {
+						continue
+					}
+					if distance < nearestDistance || distance == nearestDistance && index < nearest {
+						nearest, nearestDistance = index, distance
+					}
+				}
+			}
+		}
+	}
+	return nearest
+}
+
+// Returns the cell of the grid hash containing the given position
+func (m *MappedEvolvableSubstrateLayout) cellOf(position *PointF) gridCell {
+	return gridCell{
+		int64(math.Floor(position.X / m.epsilon)),
+		int64(math.Floor(position.Y / m.epsilon)),
+		int64(math.Floor(position.Z / m.epsilon)),
+	}
+}
+
+// The cell of the grid hash
+type gridCell [3]int64
+
 func (m *MappedEvolvableSubstrateLayout) BiasCount() int {
 	// No BIAS nodes
 	return 0
 }
 
 func (m *MappedEvolvableSubstrateLayout) InputCount() int {
-	return m.inputCount
+	return m.inputs.Count()
 }
 
 func (m *MappedEvolvableSubstrateLayout) HiddenCount() int {
@@ -137,7 +272,7 @@ func (m *MappedEvolvableSubstrateLayout) HiddenCount() int {
 }
 
 func (m *MappedEvolvableSubstrateLayout) OutputCount() int {
-	return m.outputCount
+	return m.outputs.Count()
 }
 
 func (m *MappedEvolvableSubstrateLayout) String() string {
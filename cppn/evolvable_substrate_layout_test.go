@@ -59,3 +59,115 @@ func TestMappedEvolvableSubstrateLayout_AddHiddenNode(t *testing.T) {
 		index++
 	}
 }
+
+func TestMappedEvolvableSubstrateLayout_Snapping(t *testing.T) {
+	layout, err := NewMappedEvolvableSubstrateLayoutWithTolerance(4, 2, 0.25, 0)
+	require.NoError(t, err, "failed to create layout")
+
+	index, err := layout.AddHiddenNode(&PointF{X: 0.26, Y: -0.49, Z: 0.1})
+	require.NoError(t, err, "failed to add hidden node")
+	position, err := layout.NodePosition(index, network.HiddenNeuron)
+	require.NoError(t, err, "failed to get node position")
+	assert.Equal(t, &PointF{X: 0.25, Y: -0.5, Z: 0.0}, position)
+
+	// the positions snapped to the same grid point refer to the same node
+	assert.Equal(t, index, layout.IndexOfHidden(&PointF{X: 0.3, Y: -0.55}))
+	assert.Equal(t, position, layout.SnapPosition(&PointF{X: 0.3, Y: -0.55}))
+	_, err = layout.AddHiddenNode(&PointF{X: 0.24, Y: -0.51})
+	assert.Error(t, err, "hidden node already exists")
+	assert.Equal(t, -1, layout.IndexOfHidden(&PointF{X: 0.4, Y: -0.5}))
+	assert.Equal(t, &PointF{X: 0.5, Y: -0.5}, layout.SnapPosition(&PointF{X: 0.4, Y: -0.5}))
+}
+
+func TestMappedEvolvableSubstrateLayout_truncateHidden(t *testing.T) {
+	layout, err := NewMappedEvolvableSubstrateLayoutWithTolerance(4, 2, 0, 0.01)
+	require.NoError(t, err, "failed to create layout")
+	for _, position := range []*PointF{{X: 0.1, Y: 0.1}, {X: 0.2, Y: 0.1}, {X: 0.3, Y: 0.1}} {
+		_, err = layout.AddHiddenNode(position)
+		require.NoError(t, err, "failed to add hidden node")
+	}
+
+	layout.truncateHidden(1)
+	assert.Equal(t, 1, layout.HiddenCount())
+	assert.Equal(t, 0, layout.IndexOfHidden(&PointF{X: 0.105, Y: 0.1}))
+	assert.Equal(t, -1, layout.IndexOfHidden(&PointF{X: 0.2, Y: 0.1}))
+	assert.Equal(t, -1, layout.IndexOfHidden(&PointF{X: 0.305, Y: 0.1}))
+
+	// the truncation beyond the number of hidden nodes keeps all of them
+	layout.truncateHidden(5)
+	assert.Equal(t, 1, layout.HiddenCount())
+}
+
+func TestMappedEvolvableSubstrateLayout_Merging(t *testing.T) {
+	layout, err := NewMappedEvolvableSubstrateLayoutWithTolerance(4, 2, 0, 0.01)
+	require.NoError(t, err, "failed to create layout")
+
+	first, err := layout.AddHiddenNode(&PointF{X: 0.1, Y: 0.1})
+	require.NoError(t, err, "failed to add hidden node")
+	second, err := layout.AddHiddenNode(&PointF{X: 0.12, Y: 0.1})
+	require.NoError(t, err, "failed to add hidden node")
+
+	// the floating point differences are merged
+	assert.Equal(t, first, layout.IndexOfHidden(&PointF{X: 0.1 + 1e-12, Y: 0.1 - 1e-12}))
+	_, err = layout.AddHiddenNode(&PointF{X: 0.105, Y: 0.1})
+	assert.Error(t, err, "hidden node already exists")
+
+	// the nearest node is selected
+	assert.Equal(t, second, layout.IndexOfHidden(&PointF{X: 0.114, Y: 0.1}))
+	assert.Equal(t, &PointF{X: 0.12, Y: 0.1}, layout.SnapPosition(&PointF{X: 0.114, Y: 0.1}))
+	// the node beyond epsilon is not found
+	assert.Equal(t, -1, layout.IndexOfHidden(&PointF{X: 0.1, Y: 0.12}))
+	assert.Equal(t, 2, layout.HiddenCount())
+
+	// the node added first is selected from equally distant nodes
+	layout, err = NewMappedEvolvableSubstrateLayoutWithTolerance(4, 2, 0, 0.5)
+	require.NoError(t, err, "failed to create layout")
+	_, err = layout.AddHiddenNode(&PointF{X: 0.75, Y: 0.0})
+	require.NoError(t, err, "failed to add hidden node")
+	_, err = layout.AddHiddenNode(&PointF{X: 0.0, Y: 0.0})
+	require.NoError(t, err, "failed to add hidden node")
+	assert.Equal(t, 0, layout.IndexOfHidden(&PointF{X: 0.375, Y: 0.0}))
+}
+
+func TestNewMappedEvolvableSubstrateLayoutWithTolerance_Errors(t *testing.T) {
+	_, err := NewMappedEvolvableSubstrateLayoutWithTolerance(4, 2, -0.1, 0)
+	assert.EqualError(t, err, "the snapping grid step can not be negative: -0.100000")
+	_, err = NewMappedEvolvableSubstrateLayoutWithTolerance(4, 2, 0, -0.1)
+	assert.EqualError(t, err, "the merging epsilon can not be negative: -0.100000")
+}
+
+func TestNewSheetEvolvableSubstrateLayout(t *testing.T) {
+	input := NewSheet(2, 2)
+	input.MaxY = 0.0
+	output := Sheet{Rows: 1, Cols: 2, MinX: -1.0, MaxX: 1.0, MinY: 1.0, MaxY: 1.0}
+	layout, err := NewSheetEvolvableSubstrateLayout(input, output)
+	require.NoError(t, err)
+	assert.Equal(t, 4, layout.InputCount())
+	assert.Equal(t, 2, layout.OutputCount())
+
+	inputs := []PointF{{X: -0.5, Y: -0.75}, {X: 0.5, Y: -0.75}, {X: -0.5, Y: -0.25}, {X: 0.5, Y: -0.25}}
+	for i, expected := range inputs {
+		pos, err := layout.NodePosition(i, network.InputNeuron)
+		require.NoError(t, err)
+		assert.Equal(t, expected, *pos, "wrong input position at: %d", i)
+	}
+	outputs := []PointF{{X: -0.5, Y: 1.0}, {X: 0.5, Y: 1.0}}
+	for i, expected := range outputs {
+		pos, err := layout.NodePosition(i, network.OutputNeuron)
+		require.NoError(t, err)
+		assert.Equal(t, expected, *pos, "wrong output position at: %d", i)
+	}
+	pos, err := layout.NodePosition(4, network.InputNeuron)
+	assert.EqualError(t, err, "neuron index is out of range")
+	assert.Nil(t, pos)
+}
+
+func TestNewSheetEvolvableSubstrateLayout_Errors(t *testing.T) {
+	layout, err := NewSheetEvolvableSubstrateLayout(Sheet{}, NewSheet(1, 1))
+	assert.EqualError(t, err, "the number of input neurons can not be ZERO")
+	assert.Nil(t, layout)
+
+	layout, err = NewSheetEvolvableSubstrateLayout(NewSheet(1, 1), Sheet{Rows: 1, Cols: 1, MinX: 1.0})
+	assert.EqualError(t, err, "invalid output sheet: the upper X bound of sheet can not be less than the lower one: [1.000000, 0.000000]")
+	assert.Nil(t, layout)
+}
@@ -1,11 +1,16 @@
 package cppn
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
 	"github.com/yaricom/goNEAT/v4/neat/math"
+	"github.com/yaricom/goNEAT/v4/neat/network"
 	"testing"
+	"time"
 )
 
 const esHyperNeatTestConfigFile = "../data/test/test_es_hyper.neat.yml"
@@ -67,6 +72,96 @@ func TestEvolvableSubstrate_CreateNetworkSolver_LEO(t *testing.T) {
 	checkNetworkSolverOutputs(solver, outExpected, 0.0, t)
 }
 
+func TestEvolvableSubstrate_CreateNetworkSolver_QueryCache(t *testing.T) {
+	inputCount, outputCount := 4, 2
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	// create solver without cache
+	context.CppnCacheSize = 0
+	layout, err := NewMappedEvolvableSubstrateLayout(inputCount, outputCount)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	expected, err := substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, QueryCacheStats{}, substr.QueryCacheStats(), "no statistics expected without cache")
+
+	// create solver with cache
+	context.CppnCacheSize = 10000
+	cachedLayout, err := NewMappedEvolvableSubstrateLayout(inputCount, outputCount)
+	require.NoError(t, err, "failed to create layout")
+	cachedSubstr := NewEvolvableSubstrate(cachedLayout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	solver, err := cachedSubstr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+
+	assert.Equal(t, expected.NodeCount(), solver.NodeCount(), "wrong total node count")
+	assert.Equal(t, expected.LinkCount(), solver.LinkCount(), "wrong link number")
+
+	stats := cachedSubstr.QueryCacheStats()
+	t.Log(stats)
+	assert.True(t, stats.Hits > 0, "cache hits expected")
+	assert.True(t, stats.Misses > 0, "cache misses expected")
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_ParallelExploration(t *testing.T) {
+	inputCount, outputCount := 4, 2
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+
+	// create solver with sequential exploration
+	context.ExplorationWorkers = 0
+	layout, err := NewMappedEvolvableSubstrateLayout(inputCount, outputCount)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	graph := NewSubstrateGraphMLBuilder("", false)
+	expected, err := substr.CreateNetworkSolver(cppn, graph, context)
+	require.NoError(t, err, "failed to create solver")
+
+	// create solver with parallel exploration
+	context.ExplorationWorkers = 4
+	parallelLayout, err := NewMappedEvolvableSubstrateLayout(inputCount, outputCount)
+	require.NoError(t, err, "failed to create layout")
+	parallelSubstr := NewEvolvableSubstrate(parallelLayout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	parallelGraph := NewSubstrateGraphMLBuilder("", false)
+	solver, err := parallelSubstr.CreateNetworkSolver(cppn, parallelGraph, context)
+	require.NoError(t, err, "failed to create solver")
+
+	// check that results are identical
+	assert.Equal(t, expected, solver, "solvers are different")
+	var expectedBuf, buf bytes.Buffer
+	require.NoError(t, graph.Marshal(&expectedBuf), "failed to marshal graph")
+	require.NoError(t, parallelGraph.Marshal(&buf), "failed to marshal graph")
+	assert.Equal(t, expectedBuf.String(), buf.String(), "graphs are different")
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_NodeBias(t *testing.T) {
+	inputCount, outputCount := 4, 2
+	layout, err := NewMappedEvolvableSubstrateLayout(inputCount, outputCount)
+	require.NoError(t, err, "failed to create layout")
+
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.NodeBiasEnabled = true
+
+	solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+
+	// the BIAS neuron expected
+	totalNodeCount := 1 + inputCount + outputCount + layout.HiddenCount()
+	assert.Equal(t, totalNodeCount, solver.NodeCount(), "wrong total node count")
+	// the links and a bias for each hidden and output node
+	assert.Equal(t, 27+outputCount+layout.HiddenCount(), solver.LinkCount(), "wrong link number")
+}
+
 // Loads ES-HyperNeat options from provided config file's path
 func loadESHyperNeatOptions(configPath string) (*eshyperneat.Options, error) {
 	if ctx, err := eshyperneat.LoadYAMLConfigFile(configPath); err != nil {
@@ -75,3 +170,605 @@ func loadESHyperNeatOptions(configPath string) (*eshyperneat.Options, error) {
 		return ctx, nil
 	}
 }
+
+func TestEvolvableSubstrate_CreateNetworkSolver_NodeActivatorsPalette(t *testing.T) {
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := NetworkFromGenomeFile(cppnLeoHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.LeoEnabled = true
+	palette := []math.NodeActivationType{math.GaussianBipolarActivation, math.TanhActivation}
+	context.NodeActivatorsPalette.ActivationTypes = palette
+
+	builder := NewSubstrateGraphMLBuilder("", false)
+	solver, err := substr.CreateNetworkSolver(cppn, builder, context)
+	require.NoError(t, err, "failed to create solver")
+	require.NotNil(t, solver)
+
+	// check that activation of each output and hidden node is selected by the CPPN outputs at the node position
+	activations := graphNodeActivations(builder, t)
+	firstOutput := layout.InputCount()
+	for i := firstOutput; i < solver.NodeCount(); i++ {
+		neuronType, index := network.OutputNeuron, i-firstOutput
+		if index >= layout.OutputCount() {
+			neuronType, index = network.HiddenNeuron, index-layout.OutputCount()
+		}
+		position, err := layout.NodePosition(index, neuronType)
+		require.NoError(t, err, "failed to get node position")
+		outs, err := queryCPPN([]float64{position.X, position.Y, position.Z, 0, 0, 0}, cppn)
+		require.NoError(t, err, "failed to query CPPN")
+		expected := palette[0]
+		if outs[1] > outs[0] {
+			expected = palette[1]
+		}
+		name, err := math.NodeActivators.ActivationNameFromType(expected)
+		require.NoError(t, err)
+		assert.Equal(t, name, activations[i], "wrong activation of node: %d", i)
+	}
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_Octree(t *testing.T) {
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.Depth = 1.0
+
+	solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	require.NotNil(t, solver)
+
+	// check that hidden nodes are discovered within the substrate volume and not limited to a single plane
+	require.True(t, layout.HiddenCount() > 0, "no hidden nodes discovered")
+	zPlanes := make(map[float64]bool)
+	for i := 0; i < layout.HiddenCount(); i++ {
+		position, err := layout.NodePosition(i, network.HiddenNeuron)
+		require.NoError(t, err, "failed to get hidden node position")
+		assert.True(t, position.Z >= -context.Depth && position.Z <= context.Depth, "hidden node out of volume: %s", position)
+		zPlanes[position.Z] = true
+	}
+	assert.True(t, len(zPlanes) > 1, "hidden nodes placed on a single plane")
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_ESIterations(t *testing.T) {
+	for _, genomePath := range []string{cppnHyperNEATTestGenomePath, cppnLeoHyperNEATTestGenomePath} {
+		t.Run(genomePath, func(t *testing.T) {
+			cppn, err := NetworkFromGenomeFile(genomePath)
+			require.NoError(t, err, "failed to read CPPN")
+			context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+			require.NoError(t, err, "failed to read ESHyperNEAT context")
+			context.LeoEnabled = genomePath == cppnLeoHyperNEATTestGenomePath
+
+			// create solver with one iteration
+			layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+			require.NoError(t, err, "failed to create layout")
+			substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+			context.ESIterations = 1
+			_, err = substr.CreateNetworkSolver(cppn, nil, context)
+			require.NoError(t, err, "failed to create solver")
+			require.Len(t, substr.IterationStats(), 1)
+			// the hidden nodes found by the input phase explored
+			oneIterationStats := substr.IterationStats()[0]
+			assert.Equal(t, layout.HiddenCount()-oneIterationStats.NewNodes, oneIterationStats.ExploredNodes)
+
+			// create solver with multiple iterations
+			layout, err = NewMappedEvolvableSubstrateLayout(4, 2)
+			require.NoError(t, err, "failed to create layout")
+			substr = NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+			context.ESIterations = 4
+			solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+			require.NoError(t, err, "failed to create solver")
+
+			stats := substr.IterationStats()
+			require.NotEmpty(t, stats)
+			require.True(t, len(stats) <= context.ESIterations)
+			assert.Equal(t, oneIterationStats, stats[0], "first iteration should not depend on the number of iterations")
+			for i := 1; i < len(stats); i++ {
+				// only the hidden nodes discovered by the previous iteration explored
+				assert.Equal(t, i+1, stats[i].Iteration)
+				assert.Equal(t, stats[i-1].NewNodes, stats[i].ExploredNodes, "wrong explored nodes at iteration: %d", i+1)
+			}
+			if len(stats) < context.ESIterations {
+				// iterations stopped early only if nothing left to explore
+				assert.Zero(t, stats[len(stats)-1].NewNodes)
+			}
+
+			// check that network activates
+			err = solver.LoadSensors([]float64{0.9, 5.2, 1.2, 0.6})
+			require.NoError(t, err, "failed to load sensors")
+			res, err := solver.RecursiveSteps()
+			require.NoError(t, err, "failed to perform recursive activation")
+			require.True(t, res, "failed to relax network")
+		})
+	}
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_RecurrentLinks(t *testing.T) {
+	testCases := []struct {
+		name                     string
+		genomePath               string
+		noRecurrent, noSelfLoops bool
+		expectedLinks            int
+	}{
+		{name: "recurrent and self-loops", genomePath: cppnHyperNEATTestGenomePath, expectedLinks: 27},
+		{name: "feedforward", genomePath: cppnHyperNEATTestGenomePath, noRecurrent: true, noSelfLoops: true, expectedLinks: 20},
+		{name: "LEO recurrent and self-loops", genomePath: cppnLeoHyperNEATTestGenomePath, expectedLinks: 5},
+		{name: "LEO feedforward", genomePath: cppnLeoHyperNEATTestGenomePath, noRecurrent: true, noSelfLoops: true, expectedLinks: 4},
+		{name: "LEO no recurrent", genomePath: cppnLeoHyperNEATTestGenomePath, noRecurrent: true, expectedLinks: 5},
+		{name: "LEO no self-loops", genomePath: cppnLeoHyperNEATTestGenomePath, noSelfLoops: true, expectedLinks: 4},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+			require.NoError(t, err, "failed to create layout")
+			substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+			cppn, err := NetworkFromGenomeFile(tc.genomePath)
+			require.NoError(t, err, "failed to read CPPN")
+			context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+			require.NoError(t, err, "failed to read ESHyperNEAT context")
+			context.LeoEnabled = tc.genomePath == cppnLeoHyperNEATTestGenomePath
+			context.DisableRecurrentHiddenLinks = tc.noRecurrent
+			context.DisableSelfLoopLinks = tc.noSelfLoops
+
+			solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+			require.NoError(t, err, "failed to create solver")
+			assert.Equal(t, tc.expectedLinks, solver.LinkCount(), "wrong link number")
+
+			// check that network activates
+			checkNetworkSolverOutputs(solver, []float64{0, 0}, 0.0, t)
+		})
+	}
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_OutputFeedbackLinks(t *testing.T) {
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.OutputFeedbackLinks = true
+
+	graph := NewSubstrateGraphMLBuilder("", false)
+	solver, err := substr.CreateNetworkSolver(cppn, graph, context)
+	require.NoError(t, err, "failed to create solver")
+
+	// check that feedback links from outputs to the hidden nodes are expressed
+	gml, err := graph.(*graphMLBuilder).graph()
+	require.NoError(t, err, "failed to get graph")
+	firstOutput, firstHidden := layout.InputCount(), layout.InputCount()+layout.OutputCount()
+	feedback := 0
+	for _, edge := range gml.Edges {
+		attributes, err := edge.GetAttributes()
+		require.NoError(t, err, "failed to get edge attributes")
+		source, target := attributes[edgeAttrSourceId].(int), attributes[edgeAttrTargetId].(int)
+		if source >= firstOutput && source < firstHidden {
+			assert.True(t, target >= firstHidden, "feedback link should target hidden node: %d -> %d", source, target)
+			feedback++
+		}
+	}
+	assert.True(t, feedback > 0, "no feedback links found")
+	assert.Equal(t, 27+feedback, solver.LinkCount(), "wrong link number")
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_PruneHiddenNodes(t *testing.T) {
+	inputCount, outputCount := 4, 2
+	layout, err := NewMappedEvolvableSubstrateLayout(inputCount, outputCount)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.Depth = 1.0
+	context.PruneHiddenNodes = true
+
+	graph := NewSubstrateGraphMLBuilder("", false)
+	solver, err := substr.CreateNetworkSolver(cppn, graph, context)
+	require.NoError(t, err, "failed to create solver")
+
+	pruned := substr.PrunedHiddenCount()
+	assert.True(t, pruned > 0 && pruned < layout.HiddenCount(), "wrong number of pruned nodes: %d", pruned)
+	assert.Equal(t, inputCount+outputCount+layout.HiddenCount()-pruned, solver.NodeCount(), "wrong total node count")
+
+	// check that graph holds the pruned network
+	nodesCount, err := graph.NodesCount()
+	require.NoError(t, err)
+	assert.Equal(t, solver.NodeCount(), nodesCount, "wrong graph nodes count")
+	edgesCount, err := graph.EdgesCount()
+	require.NoError(t, err)
+	assert.Equal(t, solver.LinkCount(), edgesCount, "wrong graph edges count")
+
+	// check that each remaining hidden node has incoming and outgoing links
+	gml, err := graph.(*graphMLBuilder).graph()
+	require.NoError(t, err, "failed to get graph")
+	incoming, outgoing := make(map[int]bool), make(map[int]bool)
+	for _, edge := range gml.Edges {
+		attributes, err := edge.GetAttributes()
+		require.NoError(t, err, "failed to get edge attributes")
+		outgoing[attributes[edgeAttrSourceId].(int)] = true
+		incoming[attributes[edgeAttrTargetId].(int)] = true
+	}
+	for i := inputCount + outputCount; i < solver.NodeCount(); i++ {
+		assert.True(t, incoming[i] && outgoing[i], "hidden node is not connected: %d", i)
+	}
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_PruneAllHiddenNodes(t *testing.T) {
+	inputCount, outputCount := 4, 2
+	layout, err := NewMappedEvolvableSubstrateLayout(inputCount, outputCount)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.PruneHiddenNodes = true
+
+	// no hidden node is linked to the outputs by this CPPN
+	solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, layout.HiddenCount(), substr.PrunedHiddenCount())
+	assert.Equal(t, inputCount+outputCount, solver.NodeCount(), "wrong total node count")
+	assert.Equal(t, 0, solver.LinkCount(), "wrong link number")
+}
+
+func TestConnectedHiddenNodes(t *testing.T) {
+	// bias: 0, inputs: 1, 2, outputs: 3, hidden: 4, 5, 6, 7, 8
+	links := []*network.FastNetworkLink{
+		createLink(1, 1, 4), createLink(1, 4, 5), createLink(1, 5, 3), // connected path
+		createLink(1, 2, 6),                      // dead end
+		createLink(1, 7, 3),                      // not reachable from inputs
+		createLink(1, 8, 8), createLink(1, 3, 6), // self-loop and output feedback to the dead end
+	}
+	connected := connectedHiddenNodes(links, 1, 3, 4, 5)
+	assert.Equal(t, []bool{true, true, false, false, false}, connected)
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_BandOptions(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	for _, boundary := range []eshyperneat.BandBoundaryType{eshyperneat.BandBoundaryQuery, eshyperneat.BandBoundaryClamp, eshyperneat.BandBoundaryIgnore} {
+		for _, neighbourhood := range []eshyperneat.BandNeighbourhoodType{eshyperneat.BandNeighbourhoodAxisAligned, eshyperneat.BandNeighbourhoodDiagonal} {
+			t.Run(string(boundary)+"_"+string(neighbourhood), func(t *testing.T) {
+				layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+				require.NoError(t, err, "failed to create layout")
+				substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+				context.BandBoundary, context.BandNeighbourhood = boundary, neighbourhood
+
+				solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+				require.NoError(t, err, "failed to create solver")
+				require.NotNil(t, solver)
+			})
+		}
+	}
+
+	// unsupported option
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	context.BandBoundary = "unknown"
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	assert.EqualError(t, err, "unsupported band boundary: unknown")
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_Region(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	// the hidden nodes are discovered only within the explored region
+	region := eshyperneat.Region{MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: 1.0, MinZ: 0.5, MaxZ: 0.5}
+	context.Region = &region
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	require.True(t, layout.HiddenCount() > 0, "no hidden nodes discovered")
+	for i := 0; i < layout.HiddenCount(); i++ {
+		position, err := layout.NodePosition(i, network.HiddenNeuron)
+		require.NoError(t, err, "failed to get hidden node position")
+		assert.True(t, region.Contains(position.X, position.Y, position.Z), "hidden node out of region: %s", position)
+		assert.Equal(t, 0.5, position.Z, "hidden node out of region plane: %s", position)
+	}
+
+	// the input and output nodes outside the explored region
+	region = eshyperneat.Region{MinX: 0.0, MaxX: 1.0, MinY: -1.0, MaxY: 1.0}
+	layout, err = NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr = NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	assert.NoError(t, err, "only warning expected")
+
+	context.StrictRegion = true
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "INPT 0 at")
+	assert.Contains(t, err.Error(), "OUTP 0 at")
+
+	// the invalid region
+	region = eshyperneat.Region{MinX: 1.0, MaxX: -1.0, MinY: -1.0, MaxY: 1.0}
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	assert.Error(t, err)
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_Snapping(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	step := 0.5
+	layout, err := NewMappedEvolvableSubstrateLayoutWithTolerance(4, 2, step, 0)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	require.NotNil(t, solver)
+
+	// check that hidden nodes are snapped to the grid and merged
+	require.True(t, layout.HiddenCount() > 0, "no hidden nodes discovered")
+	assert.True(t, layout.HiddenCount() <= 25, "too many hidden nodes for the grid: %d", layout.HiddenCount())
+	for i := 0; i < layout.HiddenCount(); i++ {
+		position, err := layout.NodePosition(i, network.HiddenNeuron)
+		require.NoError(t, err, "failed to get hidden node position")
+		x, y := position.X/step, position.Y/step
+		assert.True(t, x == float64(int(x)) && y == float64(int(y)), "hidden node not snapped: %s", position)
+	}
+}
+
+// nonSnappingLayout hides the snapping capability of the wrapped layout
+type nonSnappingLayout struct {
+	EvolvableSubstrateLayout
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_NonSnappingLayout(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	mapped, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	layout := &nonSnappingLayout{EvolvableSubstrateLayout: mapped}
+	_, ok := interface{}(layout).(SnappingSubstrateLayout)
+	require.False(t, ok, "layout should not support snapping")
+
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+
+	// the hidden nodes are placed at the positions discovered by the quadtree
+	assert.Equal(t, 4+2+layout.HiddenCount(), solver.NodeCount(), "wrong total node count")
+	assert.Equal(t, 27, solver.LinkCount(), "wrong link number")
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_PlasticityOutputOutOfRange(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnLeoHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.PlasticityEnabled = true
+
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+	assert.EqualError(t, err, "plasticity outputs [0, 5) are out of CPPN outputs range [2]")
+	assert.Nil(t, solver)
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_Neuromodulation(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnLeoHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.NeuromodulationEnabled = true
+	context.NeuromodulationOutput = 2
+
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	assert.EqualError(t, err, "neuromodulation requires plasticity to be enabled")
+
+	// the CPPN output deciding whether the node is modulatory is checked at the hidden node position
+	context.PlasticityEnabled = true
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	assert.EqualError(t, err, "neuromodulation output index [2] is out of CPPN outputs range [2]")
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolverContext(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	options, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	for _, workers := range []int{1, 3} {
+		options.ExplorationWorkers = workers
+
+		// the context is not cancelled
+		layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+		require.NoError(t, err, "failed to create layout")
+		substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+		solver, err := substr.CreateNetworkSolverContext(context.Background(), cppn, nil, options)
+		require.NoError(t, err, "failed to create solver")
+		assert.NotNil(t, solver)
+
+		// the cancelled context
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		layout, err = NewMappedEvolvableSubstrateLayout(4, 2)
+		require.NoError(t, err, "failed to create layout")
+		substr = NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+		solver, err = substr.CreateNetworkSolverContext(ctx, cppn, nil, options)
+		assert.Equal(t, context.Canceled, err, "workers: %d", workers)
+		assert.Nil(t, solver)
+		assert.Zero(t, layout.HiddenCount())
+
+		// the context deadline exceeded during exploration
+		ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		solver, err = substr.CreateNetworkSolverContext(ctx, cppn, nil, options)
+		cancel()
+		assert.Equal(t, context.DeadlineExceeded, err, "workers: %d", workers)
+		assert.Nil(t, solver)
+	}
+}
+
+func TestQuadTreeExplorer_Cancelled(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	options, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	limits, err := newResourceLimits(options)
+	require.NoError(t, err, "failed to create resource limits")
+	expression, err := NewLinkExpressionStrategy(options.Options)
+	require.NoError(t, err, "failed to create link expression strategy")
+	variance, err := NewVarianceFunction(options)
+	require.NoError(t, err, "failed to create variance function")
+	explorers, err := newQuadTreeExplorers(cppn, NewCoordinateEncoder(false), expression, variance, limits, options)
+	require.NoError(t, err, "failed to create explorers")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = explorers[0].queryCPPNBatch(ctx, [][]float64{{0, 0, 0, 0, 0, 0}})
+	assert.Equal(t, context.Canceled, err)
+	assert.Zero(t, explorers[0].cacheStats().Misses, "no CPPN queries expected")
+}
+
+// Creates the explorers of the test CPPN with given options
+func createTestExplorers(t *testing.T, options *eshyperneat.Options) []*quadTreeExplorer {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	limits, err := newResourceLimits(options)
+	require.NoError(t, err, "failed to create resource limits")
+	expression, err := NewLinkExpressionStrategy(options.Options)
+	require.NoError(t, err, "failed to create link expression strategy")
+	variance, err := NewVarianceFunction(options)
+	require.NoError(t, err, "failed to create variance function")
+	explorers, err := newQuadTreeExplorers(cppn, NewCoordinateEncoder(false), expression, variance, limits, options)
+	require.NoError(t, err, "failed to create explorers")
+	return explorers
+}
+
+func TestNewQuadTreeExplorers_Workers(t *testing.T) {
+	options, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	options.ExplorationWorkers = 3
+	assert.Len(t, createTestExplorers(t, options), 3)
+
+	// the exploration is sequential if CPPN queries are limited
+	options.MaxCppnQueries = 1000
+	assert.Len(t, createTestExplorers(t, options), 1)
+}
+
+func TestExplorePatterns_WorkerFailed(t *testing.T) {
+	options, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	options.ExplorationWorkers = 2
+	// the workers fail to divide the quadtree of any node
+	options.MaxQuadTreeDepth = 1
+	positions := make([]*PointF, 8)
+	for i := range positions {
+		positions[i] = &PointF{X: -1.0 + float64(i)*0.25, Y: -1.0}
+	}
+	for i := 0; i < 10; i++ {
+		explorers := createTestExplorers(t, options)
+		require.Len(t, explorers, 2)
+
+		// the failure of the worker is reported rather than cancellation of the exploration of preceding nodes
+		patterns, err := explorePatterns(context.Background(), explorers, positions, true, options)
+		var limitErr *ResourceLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, ResourceQuadTreeDepth, limitErr.Resource)
+		assert.Nil(t, patterns)
+	}
+}
+
+func TestFirstExplorationError(t *testing.T) {
+	failure := errors.New("worker failed")
+	errs := []error{nil, context.Canceled, failure, context.Canceled}
+	assert.Equal(t, failure, firstExplorationError(context.Background(), errs))
+	assert.NoError(t, firstExplorationError(context.Background(), []error{nil, nil}))
+
+	// the cancellation of the parent context is reported
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, firstExplorationError(ctx, errs))
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_ParallelExplorationLimited(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	options, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	options.MaxCppnQueries = 500
+	options.ResourceLimitPolicy = eshyperneat.ResourceLimitPolicyTruncate
+
+	// the truncated exploration is the same regardless of the number of workers
+	var expected network.Solver
+	for _, workers := range []int{0, 4} {
+		options.ExplorationWorkers = workers
+		layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+		require.NoError(t, err, "failed to create layout")
+		substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+		solver, err := substr.CreateNetworkSolver(cppn, nil, options)
+		require.NoError(t, err, "failed to create solver")
+		assert.Equal(t, []ResourceType{ResourceCppnQueries}, substr.ExceededResources())
+		if expected == nil {
+			expected = solver
+		} else {
+			assert.Equal(t, expected, solver, "solvers are different")
+		}
+	}
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_Sheets(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	options, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	input := NewSheet(2, 3)
+	input.MaxY = -0.5
+	layout, err := NewSheetEvolvableSubstrateLayout(input, NewLineSheet(2, 1.0))
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	solver, err := substr.CreateNetworkSolver(cppn, nil, options)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, 6+2+layout.HiddenCount(), solver.NodeCount())
+
+	err = solver.LoadSensors([]float64{1, 0, 1, 0, 1, 0})
+	require.NoError(t, err)
+	_, err = solver.RecursiveSteps()
+	require.NoError(t, err)
+	assert.Len(t, solver.ReadOutputs(), 2)
+}
+
+func TestWarnRegionOnce(t *testing.T) {
+	message := "the nodes are outside of the explored region: TestWarnRegionOnce"
+	assert.True(t, warnRegionOnce(message), "the first warning should be logged")
+	assert.False(t, warnRegionOnce(message), "the same warning should not be logged again")
+	assert.True(t, warnRegionOnce(message+" 2"), "the different warning should be logged")
+}
@@ -0,0 +1,339 @@
+package cppn
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	neatmath "github.com/yaricom/goNEAT/v4/neat/math"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The types of nodes of the explicit substrate layout
+const (
+	// LayoutNodeBias The BIAS node
+	LayoutNodeBias = "bias"
+	// LayoutNodeInput The input node
+	LayoutNodeInput = "input"
+	// LayoutNodeHidden The hidden node
+	LayoutNodeHidden = "hidden"
+	// LayoutNodeOutput The output node
+	LayoutNodeOutput = "output"
+)
+
+// LayoutNode Defines the node of the explicit substrate layout
+type LayoutNode struct {
+	// Name The optional unique name of the node, e.g., the name of the robot sensor
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Type The type of the node: bias, input, hidden, or output
+	Type string `yaml:"type" json:"type"`
+	// X The X coordinate of the node
+	X float64 `yaml:"x" json:"x"`
+	// Y The Y coordinate of the node
+	Y float64 `yaml:"y" json:"y"`
+	// Z The Z coordinate of the node
+	Z float64 `yaml:"z,omitempty" json:"z,omitempty"`
+	// Activation The optional name of the activation function of the hidden or output node, which takes precedence
+	// over the activation functions defined by the substrate and selected by CPPN
+	Activation string `yaml:"activation,omitempty" json:"activation,omitempty"`
+}
+
+// The document of the explicit substrate layout file
+type layoutDocument struct {
+	Nodes []LayoutNode `yaml:"nodes" json:"nodes"`
+}
+
+// ExplicitSubstrateLayout Defines the substrate layout with explicit coordinates, types, names, and optional
+// activation functions of the nodes, which can be loaded from and saved to the YAML or JSON file. The nodes of each type
+// are indexed in the order of their declaration. It implements the SubstrateLayout as well as the
+// EvolvableSubstrateLayout. In the latter case, the declared hidden nodes are treated as already discovered, and the
+// hidden nodes discovered by the evolvable substrate are not saved to the file. The discovered hidden nodes remain in
+// the layout after the network solver is created until Reset, which the evolvable substrate calls before creating the
+// network solver for the next CPPN.
+type ExplicitSubstrateLayout struct {
+	// The layout of input, output, and hidden nodes
+	mapped *MappedEvolvableSubstrateLayout
+
+	// The declared nodes in order of declaration
+	nodes []LayoutNode
+	// The positions of BIAS nodes
+	biasNodes pointsPlacement
+	// The indexes of the declared nodes of each type in the list of declared nodes
+	nodesByType map[network.NodeNeuronType][]int
+	// The indexes of the named nodes in the list of declared nodes
+	nodesByName map[string]int
+	// The activation functions of the declared nodes
+	activations []neatmath.NodeActivationType
+}
+
+// NewExplicitSubstrateLayout Creates new instance with given nodes. At least one input and one output node should be
+// declared.
+func NewExplicitSubstrateLayout(nodes []LayoutNode) (*ExplicitSubstrateLayout, error) {
+	l := &ExplicitSubstrateLayout{
+		nodes:       nodes,
+		nodesByType: make(map[network.NodeNeuronType][]int),
+		nodesByName: make(map[string]int),
+		activations: make([]neatmath.NodeActivationType, len(nodes)),
+	}
+	placements := make(map[network.NodeNeuronType]pointsPlacement)
+	for i, node := range nodes {
+		nType, err := layoutNodeType(node.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid node at index %d", i)
+		}
+		if node.Name != "" {
+			if _, ok := l.nodesByName[node.Name]; ok {
+				return nil, errors.Errorf("duplicate node name: %s", node.Name)
+			}
+			l.nodesByName[node.Name] = i
+		}
+		if node.Activation != "" {
+			if nType != network.HiddenNeuron && nType != network.OutputNeuron {
+				return nil, errors.Errorf("the activation function can be defined only for hidden and output nodes, node at index %d: %s", i, node.Type)
+			}
+			if l.activations[i], err = neatmath.NodeActivators.ActivationTypeFromName(node.Activation); err != nil {
+				return nil, errors.Wrapf(err, "invalid activation function of node at index %d", i)
+			}
+		}
+		l.nodesByType[nType] = append(l.nodesByType[nType], i)
+		placements[nType] = append(placements[nType], &PointF{X: node.X, Y: node.Y, Z: node.Z})
+	}
+	l.biasNodes = placements[network.BiasNeuron]
+
+	var err error
+	if l.mapped, err = newMappedEvolvableSubstrateLayout(placements[network.InputNeuron], placements[network.OutputNeuron], 0, 0); err != nil {
+		return nil, err
+	}
+	for _, position := range placements[network.HiddenNeuron] {
+		if _, err = l.mapped.AddHiddenNode(position); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// LoadYAMLSubstrateLayout Loads the explicit substrate layout from the provided reader of YAML document
+func LoadYAMLSubstrateLayout(r io.Reader) (*ExplicitSubstrateLayout, error) {
+	var document layoutDocument
+	if err := yaml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, errors.Wrap(err, "failed to decode substrate layout from YAML")
+	}
+	return NewExplicitSubstrateLayout(document.Nodes)
+}
+
+// LoadJSONSubstrateLayout Loads the explicit substrate layout from the provided reader of JSON document
+func LoadJSONSubstrateLayout(r io.Reader) (*ExplicitSubstrateLayout, error) {
+	var document layoutDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, errors.Wrap(err, "failed to decode substrate layout from JSON")
+	}
+	return NewExplicitSubstrateLayout(document.Nodes)
+}
+
+// LoadSubstrateLayoutFile Loads the explicit substrate layout from the file at the provided path. The format of the
+// file is defined by its extension: .yml, .yaml, or .json.
+func LoadSubstrateLayoutFile(path string) (*ExplicitSubstrateLayout, error) {
+	isYAML, err := isYAMLLayoutFile(path)
+	if err != nil {
+		return nil, err
+	}
+	layoutFile, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open substrate layout file")
+	}
+	defer func() {
+		_ = layoutFile.Close()
+	}()
+
+	if isYAML {
+		return LoadYAMLSubstrateLayout(layoutFile)
+	}
+	return LoadJSONSubstrateLayout(layoutFile)
+}
+
+// WriteYAML Writes this layout as YAML document to the provided writer
+func (l *ExplicitSubstrateLayout) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(layoutDocument{Nodes: l.nodes}); err != nil {
+		return errors.Wrap(err, "failed to encode substrate layout to YAML")
+	}
+	return enc.Close()
+}
+
+// WriteJSON Writes this layout as JSON document to the provided writer
+func (l *ExplicitSubstrateLayout) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(layoutDocument{Nodes: l.nodes}); err != nil {
+		return errors.Wrap(err, "failed to encode substrate layout to JSON")
+	}
+	return nil
+}
+
+// SaveFile Saves this layout to the file at the provided path. The format of the file is defined by its extension:
+// .yml, .yaml, or .json.
+func (l *ExplicitSubstrateLayout) SaveFile(path string) error {
+	isYAML, err := isYAMLLayoutFile(path)
+	if err != nil {
+		return err
+	}
+	layoutFile, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create substrate layout file")
+	}
+	if isYAML {
+		err = l.WriteYAML(layoutFile)
+	} else {
+		err = l.WriteJSON(layoutFile)
+	}
+	if closeErr := layoutFile.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "failed to close substrate layout file")
+	}
+	return err
+}
+
+func (l *ExplicitSubstrateLayout) NodePosition(index int, nType network.NodeNeuronType) (*PointF, error) {
+	if nType == network.BiasNeuron {
+		if index < 0 {
+			return nil, errors.New("neuron index can not be negative")
+		}
+		if index >= l.biasNodes.Count() {
+			return nil, errors.New("the BIAS index is out of range")
+		}
+		return l.biasNodes.Position(index)
+	}
+	return l.mapped.NodePosition(index, nType)
+}
+
+// NodeActivation Returns the activation function of the declared node with specified index and type, and true if it
+// is defined by this layout
+func (l *ExplicitSubstrateLayout) NodeActivation(index int, nType network.NodeNeuronType) (neatmath.NodeActivationType, bool) {
+	if i, ok := l.declaredNode(index, nType); ok && l.nodes[i].Activation != "" {
+		return l.activations[i], true
+	}
+	return 0, false
+}
+
+// NodeName Returns the name of the declared node with specified index and type, or empty string if not defined
+func (l *ExplicitSubstrateLayout) NodeName(index int, nType network.NodeNeuronType) string {
+	if i, ok := l.declaredNode(index, nType); ok {
+		return l.nodes[i].Name
+	}
+	return ""
+}
+
+// IndexOfNode Returns the index and type of the node with specified name, or -1 if not found
+func (l *ExplicitSubstrateLayout) IndexOfNode(name string) (int, network.NodeNeuronType) {
+	if i, ok := l.nodesByName[name]; ok {
+		nType, _ := layoutNodeType(l.nodes[i].Type)
+		for index, ni := range l.nodesByType[nType] {
+			if ni == i {
+				return index, nType
+			}
+		}
+	}
+	return -1, 0
+}
+
+// Nodes Returns the declared nodes of this layout
+func (l *ExplicitSubstrateLayout) Nodes() []LayoutNode {
+	return l.nodes
+}
+
+// Returns the index in the list of declared nodes of the node with specified index and type, and true if found
+func (l *ExplicitSubstrateLayout) declaredNode(index int, nType network.NodeNeuronType) (int, bool) {
+	indexes := l.nodesByType[nType]
+	if index < 0 || index >= len(indexes) {
+		return -1, false
+	}
+	return indexes[index], true
+}
+
+// Reset Removes the hidden nodes discovered by the evolvable substrate, keeping only the declared nodes
+func (l *ExplicitSubstrateLayout) Reset() {
+	l.mapped.truncateHidden(len(l.nodesByType[network.HiddenNeuron]))
+}
+
+func (l *ExplicitSubstrateLayout) AddHiddenNode(position *PointF) (int, error) {
+	return l.mapped.AddHiddenNode(position)
+}
+
+func (l *ExplicitSubstrateLayout) IndexOfHidden(position *PointF) int {
+	return l.mapped.IndexOfHidden(position)
+}
+
+func (l *ExplicitSubstrateLayout) SnapPosition(position *PointF) *PointF {
+	return l.mapped.SnapPosition(position)
+}
+
+func (l *ExplicitSubstrateLayout) BiasCount() int {
+	return l.biasNodes.Count()
+}
+
+func (l *ExplicitSubstrateLayout) InputCount() int {
+	return l.mapped.InputCount()
+}
+
+func (l *ExplicitSubstrateLayout) HiddenCount() int {
+	return l.mapped.HiddenCount()
+}
+
+func (l *ExplicitSubstrateLayout) OutputCount() int {
+	return l.mapped.OutputCount()
+}
+
+func (l *ExplicitSubstrateLayout) String() string {
+	str := fmt.Sprintf("ExplicitSubstrateLayout:\n\tINPT: %d\n\tHIDN: %d\n\tOUTP: %d\n\tBIAS: %d",
+		l.InputCount(), l.HiddenCount(), l.OutputCount(), l.BiasCount())
+	return str
+}
+
+// pointsPlacement The placement of nodes at the explicit positions
+type pointsPlacement []*PointF
+
+func (p pointsPlacement) Count() int {
+	return len(p)
+}
+
+func (p pointsPlacement) Position(index int) (*PointF, error) {
+	if index < 0 {
+		return nil, errors.New("neuron index can not be negative")
+	}
+	if index >= len(p) {
+		return nil, errors.New("neuron index is out of range")
+	}
+	return p[index], nil
+}
+
+// Returns the neuron type corresponding to the given type of layout node
+func layoutNodeType(nodeType string) (network.NodeNeuronType, error) {
+	switch nodeType {
+	case LayoutNodeBias:
+		return network.BiasNeuron, nil
+	case LayoutNodeInput:
+		return network.InputNeuron, nil
+	case LayoutNodeHidden:
+		return network.HiddenNeuron, nil
+	case LayoutNodeOutput:
+		return network.OutputNeuron, nil
+	default:
+		return 0, errors.Errorf("unsupported type of layout node: %s", nodeType)
+	}
+}
+
+// Returns true if the file at the given path is YAML file, or false if it is JSON file, according to its extension
+func isYAMLLayoutFile(path string) (bool, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yml", ".yaml":
+		return true, nil
+	case ".json":
+		return false, nil
+	default:
+		return false, errors.Errorf("unsupported substrate layout file extension: %s", ext)
+	}
+}
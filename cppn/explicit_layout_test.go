@@ -0,0 +1,318 @@
+package cppn
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goNEAT/v4/neat/math"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	explicitLayoutTestYAMLFile = "../data/test/test_substrate_layout.yml"
+	explicitLayoutTestJSONFile = "../data/test/test_substrate_layout.json"
+)
+
+func TestLoadSubstrateLayoutFile_YAML(t *testing.T) {
+	layout, err := LoadSubstrateLayoutFile(explicitLayoutTestYAMLFile)
+	require.NoError(t, err, "failed to load layout")
+	assert.Equal(t, 1, layout.BiasCount())
+	assert.Equal(t, 4, layout.InputCount())
+	assert.Equal(t, 2, layout.HiddenCount())
+	assert.Equal(t, 2, layout.OutputCount())
+	assert.Len(t, layout.Nodes(), 9)
+
+	// the positions are the same as of the grid layout
+	grid := NewGridSubstrateLayout(1, 4, 2, 2)
+	for _, nType := range []network.NodeNeuronType{network.BiasNeuron, network.InputNeuron, network.HiddenNeuron, network.OutputNeuron} {
+		count := map[network.NodeNeuronType]int{network.BiasNeuron: 1, network.InputNeuron: 4, network.HiddenNeuron: 2, network.OutputNeuron: 2}[nType]
+		for i := 0; i < count; i++ {
+			expected, err := grid.NodePosition(i, nType)
+			require.NoError(t, err)
+			pos, err := layout.NodePosition(i, nType)
+			require.NoError(t, err)
+			assert.Equal(t, expected, pos, "wrong position of %s node at: %d", network.NeuronTypeName(nType), i)
+		}
+		pos, err := layout.NodePosition(count, nType)
+		assert.Error(t, err)
+		assert.Nil(t, pos)
+	}
+
+	// names
+	assert.Equal(t, "range_front_right", layout.NodeName(2, network.InputNeuron))
+	assert.Equal(t, "", layout.NodeName(0, network.HiddenNeuron))
+	assert.Equal(t, "", layout.NodeName(10, network.InputNeuron))
+	index, nType := layout.IndexOfNode("wheel_right")
+	assert.Equal(t, 1, index)
+	assert.Equal(t, network.OutputNeuron, nType)
+	index, _ = layout.IndexOfNode("unknown")
+	assert.Equal(t, -1, index)
+
+	// activations
+	activation, ok := layout.NodeActivation(1, network.HiddenNeuron)
+	assert.True(t, ok)
+	assert.Equal(t, math.GaussianBipolarActivation, activation)
+	activation, ok = layout.NodeActivation(0, network.OutputNeuron)
+	assert.True(t, ok)
+	assert.Equal(t, math.TanhActivation, activation)
+	_, ok = layout.NodeActivation(0, network.HiddenNeuron)
+	assert.False(t, ok)
+	_, ok = layout.NodeActivation(2, network.HiddenNeuron)
+	assert.False(t, ok)
+}
+
+func TestLoadSubstrateLayoutFile_JSON(t *testing.T) {
+	layout, err := LoadSubstrateLayoutFile(explicitLayoutTestJSONFile)
+	require.NoError(t, err, "failed to load layout")
+	assert.Equal(t, 0, layout.BiasCount())
+	assert.Equal(t, 1, layout.InputCount())
+	assert.Equal(t, 0, layout.HiddenCount())
+	assert.Equal(t, 1, layout.OutputCount())
+
+	pos, err := layout.NodePosition(0, network.OutputNeuron)
+	require.NoError(t, err)
+	assert.Equal(t, PointF{X: 0, Y: 1, Z: 1}, *pos)
+	activation, ok := layout.NodeActivation(0, network.OutputNeuron)
+	assert.True(t, ok)
+	assert.Equal(t, math.SigmoidPlainActivation, activation)
+}
+
+func TestExplicitSubstrateLayout_SaveFile(t *testing.T) {
+	layout, err := LoadSubstrateLayoutFile(explicitLayoutTestYAMLFile)
+	require.NoError(t, err, "failed to load layout")
+
+	for _, name := range []string{"layout.yml", "layout.yaml", "layout.json"} {
+		path := filepath.Join(t.TempDir(), name)
+		err = layout.SaveFile(path)
+		require.NoError(t, err, "failed to save layout: %s", name)
+
+		loaded, err := LoadSubstrateLayoutFile(path)
+		require.NoError(t, err, "failed to load saved layout: %s", name)
+		assert.Equal(t, layout.Nodes(), loaded.Nodes(), name)
+	}
+
+	err = layout.SaveFile(filepath.Join(t.TempDir(), "layout.txt"))
+	assert.EqualError(t, err, "unsupported substrate layout file extension: .txt")
+	loaded, err := LoadSubstrateLayoutFile("layout.xml")
+	assert.EqualError(t, err, "unsupported substrate layout file extension: .xml")
+	assert.Nil(t, loaded)
+}
+
+func TestExplicitSubstrateLayout_WriteYAML(t *testing.T) {
+	layout, err := NewExplicitSubstrateLayout([]LayoutNode{
+		{Name: "sensor", Type: LayoutNodeInput, Y: -1},
+		{Type: LayoutNodeOutput, X: 0.5, Y: 1, Activation: "TanhActivation"},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = layout.WriteYAML(&buf)
+	require.NoError(t, err)
+	expected := `nodes:
+  - name: sensor
+    type: input
+    x: 0
+    "y": -1
+  - type: output
+    x: 0.5
+    "y": 1
+    activation: TanhActivation
+`
+	assert.Equal(t, expected, buf.String())
+
+	buf.Reset()
+	err = layout.WriteJSON(&buf)
+	require.NoError(t, err)
+	expected = `{
+  "nodes": [
+    {
+      "name": "sensor",
+      "type": "input",
+      "x": 0,
+      "y": -1
+    },
+    {
+      "type": "output",
+      "x": 0.5,
+      "y": 1,
+      "activation": "TanhActivation"
+    }
+  ]
+}
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestNewExplicitSubstrateLayout_Errors(t *testing.T) {
+	input := LayoutNode{Name: "in", Type: LayoutNodeInput, Y: -1}
+	output := LayoutNode{Name: "out", Type: LayoutNodeOutput, Y: 1}
+	testCases := []struct {
+		nodes []LayoutNode
+		err   string
+	}{
+		{nodes: []LayoutNode{input, {Type: "sensor"}, output}, err: "invalid node at index 1: unsupported type of layout node: sensor"},
+		{nodes: []LayoutNode{input, input, output}, err: "duplicate node name: in"},
+		{nodes: []LayoutNode{{Type: LayoutNodeInput, Activation: "TanhActivation"}, output},
+			err: "the activation function can be defined only for hidden and output nodes, node at index 0: input"},
+		{nodes: []LayoutNode{input, {Type: LayoutNodeOutput, Activation: "Unknown"}},
+			err: "invalid activation function of node at index 1: unsupported activation type name: Unknown"},
+		{nodes: []LayoutNode{input}, err: "the number of output neurons can not be ZERO"},
+		{nodes: []LayoutNode{output}, err: "the number of input neurons can not be ZERO"},
+		{nodes: []LayoutNode{input, {Type: LayoutNodeHidden}, {Type: LayoutNodeHidden}, output},
+			err: "hidden node already exists at the position: (0.000000, 0.000000, 0.000000)"},
+	}
+	for _, tc := range testCases {
+		layout, err := NewExplicitSubstrateLayout(tc.nodes)
+		assert.EqualError(t, err, tc.err)
+		assert.Nil(t, layout)
+	}
+
+	layout, err := LoadJSONSubstrateLayout(strings.NewReader("{nodes"))
+	assert.ErrorContains(t, err, "failed to decode substrate layout from JSON")
+	assert.Nil(t, layout)
+	layout, err = LoadYAMLSubstrateLayout(strings.NewReader("nodes: 1"))
+	assert.ErrorContains(t, err, "failed to decode substrate layout from YAML")
+	assert.Nil(t, layout)
+}
+
+func TestSubstrate_CreateNetworkSolver_ExplicitLayout(t *testing.T) {
+	layout, err := LoadSubstrateLayoutFile(explicitLayoutTestYAMLFile)
+	require.NoError(t, err, "failed to load layout")
+	cppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+
+	// the layout without activations is the same as the grid layout
+	nodes := make([]LayoutNode, len(layout.Nodes()))
+	for i, node := range layout.Nodes() {
+		node.Activation = ""
+		nodes[i] = node
+	}
+	plain, err := NewExplicitSubstrateLayout(nodes)
+	require.NoError(t, err)
+	substr := NewSubstrate(plain, math.SigmoidSteepenedActivation, math.LinearActivation)
+	solver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+	assert.Equal(t, 9, solver.NodeCount())
+	assert.Equal(t, 12, solver.LinkCount())
+	checkNetworkSolverOutputs(solver, []float64{1.0250491652984794, 1.5100754688624802}, 0.0, t)
+
+	// the activations defined by layout take precedence
+	substr = NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	graph := NewSubstrateGraphMLBuilder("", false)
+	solver, err = substr.CreateNetworkSolver(cppn, false, graph, context)
+	require.NoError(t, err, "failed to create network solver")
+	var buf bytes.Buffer
+	err = graph.Marshal(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "TanhActivation")
+	assert.Contains(t, buf.String(), "GaussianBipolarActivation")
+	outputs := solver.ReadOutputs()
+	assert.True(t, outputs[0] >= -1 && outputs[0] <= 1, "output is not in the range of tanh: %f", outputs[0])
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_ExplicitLayout(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	options, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	nodes := []LayoutNode{
+		{Name: "left", Type: LayoutNodeInput, X: -0.5, Y: -1},
+		{Name: "center", Type: LayoutNodeInput, X: 0, Y: -1},
+		{Name: "right", Type: LayoutNodeInput, X: 0.5, Y: -1},
+		{Name: "motor", Type: LayoutNodeOutput, X: 0, Y: 1, Activation: "TanhActivation"},
+	}
+	layout, err := NewExplicitSubstrateLayout(nodes)
+	require.NoError(t, err)
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	graph := NewSubstrateGraphMLBuilder("", false)
+	solver, err := substr.CreateNetworkSolver(cppn, graph, options)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, 3+1+layout.HiddenCount(), solver.NodeCount())
+
+	var buf bytes.Buffer
+	err = graph.Marshal(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "TanhActivation")
+
+	// the discovered hidden nodes are not saved
+	buf.Reset()
+	err = layout.WriteYAML(&buf)
+	require.NoError(t, err)
+	loaded, err := LoadYAMLSubstrateLayout(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, nodes, loaded.Nodes())
+	assert.Zero(t, loaded.HiddenCount())
+
+	// the layout is reset automatically, thus the same network is created for the same CPPN
+	hiddenCount := layout.HiddenCount()
+	solver, err = substr.CreateNetworkSolver(cppn, nil, options)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, hiddenCount, layout.HiddenCount())
+	assert.Equal(t, 3+1+hiddenCount, solver.NodeCount())
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_ExplicitLayoutReused(t *testing.T) {
+	options, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	nodes := []LayoutNode{
+		{Type: LayoutNodeInput, X: -0.5, Y: -1},
+		{Type: LayoutNodeInput, X: 0.5, Y: -1},
+		{Type: LayoutNodeHidden, X: 0, Y: 0},
+		{Type: LayoutNodeOutput, X: 0, Y: 1},
+	}
+	// creates the network solver for the CPPN from a given genome using a given substrate
+	createSolver := func(substr *EvolvableSubstrate, genomePath string) network.Solver {
+		cppn, err := NetworkFromGenomeFile(genomePath)
+		require.NoError(t, err, "failed to read CPPN")
+		options.LeoEnabled = genomePath == cppnLeoHyperNEATTestGenomePath
+		solver, err := substr.CreateNetworkSolver(cppn, nil, options)
+		require.NoError(t, err, "failed to create solver")
+		return solver
+	}
+
+	layout, err := NewExplicitSubstrateLayout(nodes)
+	require.NoError(t, err)
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	genomePaths := []string{cppnHyperNEATTestGenomePath, cppnLeoHyperNEATTestGenomePath, cppnHyperNEATTestGenomePath}
+	for _, genomePath := range genomePaths {
+		solver := createSolver(substr, genomePath)
+
+		// the network is the same as created with the fresh layout
+		freshLayout, err := NewExplicitSubstrateLayout(nodes)
+		require.NoError(t, err)
+		freshSubstr := NewEvolvableSubstrate(freshLayout, math.SigmoidSteepenedActivation, math.LinearActivation)
+		expected := createSolver(freshSubstr, genomePath)
+		assert.Equal(t, freshLayout.HiddenCount(), layout.HiddenCount(), genomePath)
+		assert.Equal(t, expected.NodeCount(), solver.NodeCount(), genomePath)
+		assert.Equal(t, expected.LinkCount(), solver.LinkCount(), genomePath)
+	}
+}
+
+func TestExplicitSubstrateLayout_Reset(t *testing.T) {
+	layout, err := NewExplicitSubstrateLayout([]LayoutNode{
+		{Type: LayoutNodeInput, X: 0, Y: -1},
+		{Type: LayoutNodeHidden, X: -0.5, Y: 0},
+		{Type: LayoutNodeHidden, X: 0.5, Y: 0},
+		{Type: LayoutNodeOutput, X: 0, Y: 1},
+	})
+	require.NoError(t, err)
+	discovered := &PointF{X: 0, Y: 0.5}
+	index, err := layout.AddHiddenNode(discovered)
+	require.NoError(t, err, "failed to add hidden node")
+	assert.Equal(t, 2, index)
+
+	// the discovered hidden node is removed, while the declared ones are kept
+	layout.Reset()
+	assert.Equal(t, 2, layout.HiddenCount())
+	assert.Equal(t, -1, layout.IndexOfHidden(discovered))
+	assert.Equal(t, 1, layout.IndexOfHidden(&PointF{X: 0.5, Y: 0}))
+	index, err = layout.AddHiddenNode(discovered)
+	require.NoError(t, err, "failed to add hidden node")
+	assert.Equal(t, 2, index)
+}
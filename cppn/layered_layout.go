@@ -0,0 +1,262 @@
+package cppn
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"strings"
+)
+
+// The names of the layers of the substrate layout which does not define named layers
+const (
+	// InputLayerName The name of the layer of all input nodes
+	InputLayerName = "input"
+	// HiddenLayerName The name of the layer of all hidden nodes
+	HiddenLayerName = "hidden"
+	// OutputLayerName The name of the layer of all output nodes
+	OutputLayerName = "output"
+)
+
+// SubstrateLayer Defines the named layer of nodes in the multi-layer substrate layout
+type SubstrateLayer struct {
+	// Name The unique name of the layer
+	Name string
+	// Type The type of the layer nodes: input, hidden, or output
+	Type network.NodeNeuronType
+	// Sheet The sheet defining the number and positions of the layer nodes
+	Sheet Sheet
+}
+
+func (l SubstrateLayer) String() string {
+	return fmt.Sprintf("%s [%s]: %s", l.Name, network.NeuronTypeName(l.Type), l.Sheet)
+}
+
+// LayerConnection Defines the connection between named layers of the substrate, which links all nodes of the source
+// layer to all nodes of the target layer
+type LayerConnection struct {
+	// Source The name of the source layer
+	Source string
+	// Target The name of the target layer
+	Target string
+}
+
+func (c LayerConnection) String() string {
+	return fmt.Sprintf("%s -> %s", c.Source, c.Target)
+}
+
+// LayeredLayout Defines the substrate layout with nodes arranged in named layers. The nodes of each type are indexed in
+// the order of declaration of the layers of that type, i.e., the first hidden node of the second hidden layer follows
+// the last hidden node of the first hidden layer.
+type LayeredLayout interface {
+	SubstrateLayout
+
+	// Layers Returns the layers of this layout in order of declaration
+	Layers() []SubstrateLayer
+}
+
+// LayeredSubstrateLayout Defines the multi-layer substrate layout with any number of named input, hidden, and output
+// layers of nodes placed at configurable positions
+type LayeredSubstrateLayout struct {
+	// The number of bias nodes encoded in this substrate
+	biasCount int
+	// The layers of nodes in order of declaration
+	layers []SubstrateLayer
+	// The indexes of the layers of each node type in order of declaration
+	layersByType map[network.NodeNeuronType][]int
+}
+
+// NewLayeredSubstrateLayout Creates new instance with specified number of BIAS nodes and given layers of nodes. The BIAS
+// nodes are located at the origin. At least one input and one output layer should be declared.
+func NewLayeredSubstrateLayout(biasCount int, layers []SubstrateLayer) (*LayeredSubstrateLayout, error) {
+	if biasCount < 0 {
+		return nil, errors.Errorf("the number of BIAS neurons can not be negative: %d", biasCount)
+	}
+	l := &LayeredSubstrateLayout{
+		biasCount:    biasCount,
+		layers:       layers,
+		layersByType: make(map[network.NodeNeuronType][]int),
+	}
+	names := make(map[string]bool)
+	for i, layer := range layers {
+		if len(strings.TrimSpace(layer.Name)) == 0 {
+			return nil, errors.Errorf("the name of layer at index %d is empty", i)
+		}
+		if names[layer.Name] {
+			return nil, errors.Errorf("duplicate layer name: %s", layer.Name)
+		}
+		names[layer.Name] = true
+		switch layer.Type {
+		case network.InputNeuron, network.HiddenNeuron, network.OutputNeuron:
+		default:
+			return nil, errors.Errorf("unsupported type of layer %s: %s", layer.Name, network.NeuronTypeName(layer.Type))
+		}
+		if err := layer.Sheet.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid sheet of layer %s", layer.Name)
+		}
+		l.layersByType[layer.Type] = append(l.layersByType[layer.Type], i)
+	}
+	if len(l.layersByType[network.InputNeuron]) == 0 {
+		return nil, errors.New("at least one input layer should be defined")
+	}
+	if len(l.layersByType[network.OutputNeuron]) == 0 {
+		return nil, errors.New("at least one output layer should be defined")
+	}
+	return l, nil
+}
+
+func (l *LayeredSubstrateLayout) NodePosition(index int, nType network.NodeNeuronType) (*PointF, error) {
+	if index < 0 {
+		return nil, errors.New("neuron index can not be negative")
+	}
+	if nType == network.BiasNeuron {
+		if index < l.biasCount {
+			return &PointF{}, nil // BIAS always located at (0, 0)
+		}
+		return nil, errors.New("the BIAS index is out of range")
+	}
+	for _, li := range l.layersByType[nType] {
+		sheet := l.layers[li].Sheet
+		if index < sheet.Count() {
+			return sheet.Position(index)
+		}
+		index -= sheet.Count()
+	}
+	return nil, errors.New("neuron index is out of range")
+}
+
+func (l *LayeredSubstrateLayout) Layers() []SubstrateLayer {
+	return l.layers
+}
+
+// SequentialConnections Returns the connections linking the layers in order of their types: each input layer to the
+// first hidden layer, each hidden layer to the next one in order of declaration, and the last hidden layer to each
+// output layer, e.g., input→h1, h1→h2, h2→output. Without hidden layers, each input layer is linked to each output
+// layer. The hidden layers without nodes are skipped.
+func (l *LayeredSubstrateLayout) SequentialConnections() []LayerConnection {
+	return sequentialConnections(layerNamesByType(l.layers))
+}
+
+func (l *LayeredSubstrateLayout) BiasCount() int {
+	return l.biasCount
+}
+
+func (l *LayeredSubstrateLayout) InputCount() int {
+	return l.countOf(network.InputNeuron)
+}
+
+func (l *LayeredSubstrateLayout) HiddenCount() int {
+	return l.countOf(network.HiddenNeuron)
+}
+
+func (l *LayeredSubstrateLayout) OutputCount() int {
+	return l.countOf(network.OutputNeuron)
+}
+
+// Returns the number of nodes of the given type in all layers
+func (l *LayeredSubstrateLayout) countOf(nType network.NodeNeuronType) int {
+	count := 0
+	for _, li := range l.layersByType[nType] {
+		count += l.layers[li].Sheet.Count()
+	}
+	return count
+}
+
+func (l *LayeredSubstrateLayout) String() string {
+	str := fmt.Sprintf("LayeredSubstrateLayout:\n\tBIAS: %d", l.biasCount)
+	for _, layer := range l.layers {
+		str += fmt.Sprintf("\n\t%s", layer)
+	}
+	return str
+}
+
+// layerNodes holds the nodes of the named substrate layer as a range of layout nodes of the same type
+type layerNodes struct {
+	// The type of the layer nodes
+	nType network.NodeNeuronType
+	// The index of the first layer node among the layout nodes of the same type
+	offset int
+	// The number of nodes in the layer
+	count int
+}
+
+// Returns the names of layers grouped by type in order of declaration, skipping the hidden layers without nodes
+func layerNamesByType(layers []SubstrateLayer) map[network.NodeNeuronType][]string {
+	names := make(map[network.NodeNeuronType][]string)
+	for _, layer := range layers {
+		if layer.Type == network.HiddenNeuron && layer.Sheet.Count() == 0 {
+			continue
+		}
+		names[layer.Type] = append(names[layer.Type], layer.Name)
+	}
+	return names
+}
+
+// Returns the connections linking the named layers of each type in order input→hidden→output, with hidden layers
+// chained in the given order
+func sequentialConnections(names map[network.NodeNeuronType][]string) []LayerConnection {
+	connections := make([]LayerConnection, 0)
+	sources := names[network.InputNeuron]
+	for _, hidden := range names[network.HiddenNeuron] {
+		for _, source := range sources {
+			connections = append(connections, LayerConnection{Source: source, Target: hidden})
+		}
+		sources = []string{hidden}
+	}
+	for _, output := range names[network.OutputNeuron] {
+		for _, source := range sources {
+			connections = append(connections, LayerConnection{Source: source, Target: output})
+		}
+	}
+	return connections
+}
+
+// Resolves the layers of the substrate layout into ranges of nodes, and the connection plan between them. The layouts
+// without named layers have the input, hidden, and output layers. If the connection plan is not set, the layers are
+// connected sequentially in order input→hidden→output as in LayeredSubstrateLayout.SequentialConnections, and the
+// layouts without hidden nodes are connected input→output.
+func resolveConnectionPlan(layout SubstrateLayout, connections []LayerConnection) (map[string]layerNodes, []LayerConnection, error) {
+	layers := make(map[string]layerNodes)
+	var names map[network.NodeNeuronType][]string
+	if layered, ok := layout.(LayeredLayout); ok {
+		offsets := make(map[network.NodeNeuronType]int)
+		for _, layer := range layered.Layers() {
+			layers[layer.Name] = layerNodes{nType: layer.Type, offset: offsets[layer.Type], count: layer.Sheet.Count()}
+			offsets[layer.Type] += layer.Sheet.Count()
+		}
+		names = layerNamesByType(layered.Layers())
+	} else {
+		layers[InputLayerName] = layerNodes{nType: network.InputNeuron, count: layout.InputCount()}
+		layers[HiddenLayerName] = layerNodes{nType: network.HiddenNeuron, count: layout.HiddenCount()}
+		layers[OutputLayerName] = layerNodes{nType: network.OutputNeuron, count: layout.OutputCount()}
+		names = map[network.NodeNeuronType][]string{
+			network.InputNeuron:  {InputLayerName},
+			network.OutputNeuron: {OutputLayerName},
+		}
+		if layout.HiddenCount() > 0 {
+			names[network.HiddenNeuron] = []string{HiddenLayerName}
+		}
+	}
+
+	if connections == nil {
+		// connect layers sequentially, the generated plan is validated as well
+		connections = sequentialConnections(names)
+	}
+
+	// validate the connection plan
+	planned := make(map[LayerConnection]bool)
+	for _, connection := range connections {
+		if _, ok := layers[connection.Source]; !ok {
+			return nil, nil, errors.Errorf("unknown source layer of connection: %s", connection)
+		}
+		if target, ok := layers[connection.Target]; !ok {
+			return nil, nil, errors.Errorf("unknown target layer of connection: %s", connection)
+		} else if target.nType == network.InputNeuron {
+			return nil, nil, errors.Errorf("the input layer can not be the target of connection: %s", connection)
+		}
+		if planned[connection] {
+			return nil, nil, errors.Errorf("duplicate connection: %s", connection)
+		}
+		planned[connection] = true
+	}
+	return layers, connections, nil
+}
@@ -0,0 +1,176 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"testing"
+)
+
+func createTestLayeredLayout(t *testing.T) *LayeredSubstrateLayout {
+	layout, err := NewLayeredSubstrateLayout(0, []SubstrateLayer{
+		{Name: "retina", Type: network.InputNeuron, Sheet: NewLineSheet(2, -1.0)},
+		{Name: "h1", Type: network.HiddenNeuron, Sheet: NewLineSheet(2, -0.5)},
+		{Name: "h2", Type: network.HiddenNeuron, Sheet: NewLineSheet(1, 0.5)},
+		{Name: "decision", Type: network.OutputNeuron, Sheet: NewLineSheet(1, 1.0)},
+	})
+	require.NoError(t, err, "failed to create layout")
+	return layout
+}
+
+func TestLayeredSubstrateLayout_NodePosition(t *testing.T) {
+	layout := createTestLayeredLayout(t)
+	assert.Equal(t, 0, layout.BiasCount())
+	assert.Equal(t, 2, layout.InputCount())
+	assert.Equal(t, 3, layout.HiddenCount())
+	assert.Equal(t, 1, layout.OutputCount())
+
+	testCases := []struct {
+		nType    network.NodeNeuronType
+		expected []PointF
+	}{
+		{nType: network.InputNeuron, expected: []PointF{{X: -0.5, Y: -1.0}, {X: 0.5, Y: -1.0}}},
+		{nType: network.HiddenNeuron, expected: []PointF{{X: -0.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: 0.0, Y: 0.5}}},
+		{nType: network.OutputNeuron, expected: []PointF{{X: 0.0, Y: 1.0}}},
+	}
+	for _, tc := range testCases {
+		for i, expected := range tc.expected {
+			pos, err := layout.NodePosition(i, tc.nType)
+			require.NoError(t, err)
+			assert.Equal(t, expected, *pos, "wrong position of %s node at: %d", network.NeuronTypeName(tc.nType), i)
+		}
+		pos, err := layout.NodePosition(len(tc.expected), tc.nType)
+		assert.EqualError(t, err, "neuron index is out of range")
+		assert.Nil(t, pos)
+	}
+	pos, err := layout.NodePosition(0, network.BiasNeuron)
+	assert.EqualError(t, err, "the BIAS index is out of range")
+	assert.Nil(t, pos)
+}
+
+func TestLayeredSubstrateLayout_SequentialConnections(t *testing.T) {
+	layout := createTestLayeredLayout(t)
+	expected := []LayerConnection{
+		{Source: "retina", Target: "h1"},
+		{Source: "h1", Target: "h2"},
+		{Source: "h2", Target: "decision"},
+	}
+	assert.Equal(t, expected, layout.SequentialConnections())
+
+	// the layers are connected in order of their types regardless of declaration order
+	layer := func(name string, nType network.NodeNeuronType, count int) SubstrateLayer {
+		return SubstrateLayer{Name: name, Type: nType, Sheet: NewLineSheet(count, 0.0)}
+	}
+	testCases := []struct {
+		name     string
+		layers   []SubstrateLayer
+		expected []LayerConnection
+	}{
+		{
+			name: "consecutive inputs",
+			layers: []SubstrateLayer{
+				layer("left", network.InputNeuron, 2), layer("right", network.InputNeuron, 2),
+				layer("h", network.HiddenNeuron, 2), layer("out", network.OutputNeuron, 1)},
+			expected: []LayerConnection{{Source: "left", Target: "h"}, {Source: "right", Target: "h"}, {Source: "h", Target: "out"}},
+		},
+		{
+			name: "output before hidden",
+			layers: []SubstrateLayer{
+				layer("in", network.InputNeuron, 2), layer("out", network.OutputNeuron, 1),
+				layer("h1", network.HiddenNeuron, 2), layer("h2", network.HiddenNeuron, 2)},
+			expected: []LayerConnection{{Source: "in", Target: "h1"}, {Source: "h1", Target: "h2"}, {Source: "h2", Target: "out"}},
+		},
+		{
+			name: "no hidden",
+			layers: []SubstrateLayer{
+				layer("in1", network.InputNeuron, 2), layer("in2", network.InputNeuron, 2),
+				layer("out1", network.OutputNeuron, 1), layer("out2", network.OutputNeuron, 1)},
+			expected: []LayerConnection{
+				{Source: "in1", Target: "out1"}, {Source: "in2", Target: "out1"},
+				{Source: "in1", Target: "out2"}, {Source: "in2", Target: "out2"}},
+		},
+		{
+			name: "empty hidden",
+			layers: []SubstrateLayer{
+				layer("in", network.InputNeuron, 2), layer("h1", network.HiddenNeuron, 2),
+				layer("h2", network.HiddenNeuron, 0), layer("out", network.OutputNeuron, 1)},
+			expected: []LayerConnection{{Source: "in", Target: "h1"}, {Source: "h1", Target: "out"}},
+		},
+	}
+	for _, tc := range testCases {
+		layout, err := NewLayeredSubstrateLayout(0, tc.layers)
+		require.NoError(t, err, "failed to create layout: %s", tc.name)
+		assert.Equal(t, tc.expected, layout.SequentialConnections(), tc.name)
+
+		// the default connection plan is the same
+		_, connections, err := resolveConnectionPlan(layout, nil)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, connections, tc.name)
+	}
+}
+
+func TestNewLayeredSubstrateLayout_Errors(t *testing.T) {
+	input := SubstrateLayer{Name: "input", Type: network.InputNeuron, Sheet: NewLineSheet(2, -1.0)}
+	output := SubstrateLayer{Name: "output", Type: network.OutputNeuron, Sheet: NewLineSheet(1, 1.0)}
+	testCases := []struct {
+		biasCount int
+		layers    []SubstrateLayer
+		err       string
+	}{
+		{biasCount: -1, layers: []SubstrateLayer{input, output}, err: "the number of BIAS neurons can not be negative: -1"},
+		{layers: []SubstrateLayer{input, {Type: network.HiddenNeuron}, output}, err: "the name of layer at index 1 is empty"},
+		{layers: []SubstrateLayer{input, input, output}, err: "duplicate layer name: input"},
+		{layers: []SubstrateLayer{input, {Name: "bias", Type: network.BiasNeuron}, output},
+			err: "unsupported type of layer bias: BIAS"},
+		{layers: []SubstrateLayer{input, {Name: "h", Type: network.HiddenNeuron, Sheet: Sheet{Rows: -1}}, output},
+			err: "invalid sheet of layer h: the number of rows and columns of sheet can not be negative: -1 x 0"},
+		{layers: []SubstrateLayer{output}, err: "at least one input layer should be defined"},
+		{layers: []SubstrateLayer{input}, err: "at least one output layer should be defined"},
+	}
+	for _, tc := range testCases {
+		layout, err := NewLayeredSubstrateLayout(tc.biasCount, tc.layers)
+		assert.EqualError(t, err, tc.err)
+		assert.Nil(t, layout)
+	}
+}
+
+func TestResolveConnectionPlan(t *testing.T) {
+	// the layout without named layers
+	layers, connections, err := resolveConnectionPlan(NewGridSubstrateLayout(1, 4, 2, 3), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]layerNodes{
+		InputLayerName:  {nType: network.InputNeuron, count: 4},
+		HiddenLayerName: {nType: network.HiddenNeuron, count: 3},
+		OutputLayerName: {nType: network.OutputNeuron, count: 2},
+	}, layers)
+	assert.Equal(t, []LayerConnection{{Source: InputLayerName, Target: HiddenLayerName}, {Source: HiddenLayerName, Target: OutputLayerName}}, connections)
+
+	// the layout without hidden nodes
+	_, connections, err = resolveConnectionPlan(NewGridSubstrateLayout(1, 4, 2, 0), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []LayerConnection{{Source: InputLayerName, Target: OutputLayerName}}, connections)
+
+	// the layered layout
+	layers, connections, err = resolveConnectionPlan(createTestLayeredLayout(t), nil)
+	require.NoError(t, err)
+	assert.Equal(t, layerNodes{nType: network.HiddenNeuron, offset: 2, count: 1}, layers["h2"])
+	assert.Len(t, connections, 3)
+
+	// the invalid plans
+	testCases := []struct {
+		connection LayerConnection
+		err        string
+	}{
+		{connection: LayerConnection{Source: "h3", Target: "h2"}, err: "unknown source layer of connection: h3 -> h2"},
+		{connection: LayerConnection{Source: "h1", Target: "h3"}, err: "unknown target layer of connection: h1 -> h3"},
+		{connection: LayerConnection{Source: "h1", Target: "retina"}, err: "the input layer can not be the target of connection: h1 -> retina"},
+		{connection: LayerConnection{Source: "retina", Target: "h1"}, err: "duplicate connection: retina -> h1"},
+	}
+	for _, tc := range testCases {
+		plan := []LayerConnection{{Source: "retina", Target: "h1"}, tc.connection}
+		layers, connections, err = resolveConnectionPlan(createTestLayeredLayout(t), plan)
+		assert.EqualError(t, err, tc.err)
+		assert.Nil(t, layers)
+		assert.Nil(t, connections)
+	}
+}
@@ -0,0 +1,192 @@
+package cppn
+
+import (
+	"github.com/pkg/errors"
+	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
+	"math"
+)
+
+// LinkExpressionStrategy Defines the strategy to decide whether a link between two substrate nodes should be
+// expressed and what weight it should have, given the CPPN outputs queried for the link.
+type LinkExpressionStrategy interface {
+	// ExpressLink Returns the weight of the link between source and target nodes and true if the link should be
+	// expressed, given the weight and LEO outputs of the CPPN.
+	ExpressLink(weight, leo float64, source, target PointF) (float64, bool)
+	// UsesLeo Returns true if link expression depends on the CPPN LEO output
+	UsesLeo() bool
+}
+
+// ThresholdLinkExpression Expresses links with absolute value of the CPPN weight output not less than Threshold. The
+// weight is produced by Mapping if set, or normalized by threshold and scaled to fit WeightRange otherwise.
+type ThresholdLinkExpression struct {
+	// Threshold The link threshold value
+	Threshold float64
+	// WeightRange The range of the link weights
+	WeightRange float64
+	// Mapping The optional mapping of the CPPN weight output into the link weight
+	Mapping WeightMapping
+}
+
+func (t ThresholdLinkExpression) ExpressLink(weight, _ float64, _, _ PointF) (float64, bool) {
+	if math.Abs(weight) >= t.Threshold {
+		if t.Mapping != nil {
+			return t.Mapping.MapWeight(weight), true
+		}
+		return ThresholdNormalizedWeightMapping{Threshold: t.Threshold, WeightRange: t.WeightRange}.MapWeight(weight), true
+	}
+	return 0, false
+}
+
+func (t ThresholdLinkExpression) UsesLeo() bool {
+	return false
+}
+
+// LeoLinkExpression Expresses links with the CPPN LEO output greater than ExpressionThreshold. The weight is produced
+// by Mapping if set, or scaled to fit WeightRange otherwise.
+type LeoLinkExpression struct {
+	// ExpressionThreshold The threshold value LEO output should exceed, zero by default
+	ExpressionThreshold float64
+	// WeightRange The range of the link weights
+	WeightRange float64
+	// Mapping The optional mapping of the CPPN weight output into the link weight
+	Mapping WeightMapping
+}
+
+func (l LeoLinkExpression) ExpressLink(weight, leo float64, _, _ PointF) (float64, bool) {
+	if leo > l.ExpressionThreshold {
+		if l.Mapping != nil {
+			return l.Mapping.MapWeight(weight), true
+		}
+		return LinearWeightMapping{WeightRange: l.WeightRange}.MapWeight(weight), true
+	}
+	return 0, false
+}
+
+func (l LeoLinkExpression) UsesLeo() bool {
+	return true
+}
+
+// LeoThresholdLinkExpression Expresses links with the CPPN LEO output greater than ExpressionThreshold and absolute
+// value of the CPPN weight output not less than WeightThreshold. The weight is produced by Mapping if set, or normalized
+// by threshold and scaled to fit WeightRange otherwise.
+type LeoThresholdLinkExpression struct {
+	// ExpressionThreshold The threshold value LEO output should exceed
+	ExpressionThreshold float64
+	// WeightThreshold The link weight threshold value
+	WeightThreshold float64
+	// WeightRange The range of the link weights
+	WeightRange float64
+	// Mapping The optional mapping of the CPPN weight output into the link weight
+	Mapping WeightMapping
+}
+
+func (l LeoThresholdLinkExpression) ExpressLink(weight, leo float64, _, _ PointF) (float64, bool) {
+	if leo > l.ExpressionThreshold && math.Abs(weight) >= l.WeightThreshold {
+		if l.Mapping != nil {
+			return l.Mapping.MapWeight(weight), true
+		}
+		return ThresholdNormalizedWeightMapping{Threshold: l.WeightThreshold, WeightRange: l.WeightRange}.MapWeight(weight), true
+	}
+	return 0, false
+}
+
+func (l LeoThresholdLinkExpression) UsesLeo() bool {
+	return true
+}
+
+// GaussianLocalityLeoLinkExpression Expresses links with the CPPN LEO output seeded with locality greater than
+// ExpressionThreshold. The locality seed is the Gaussian of the distance between linked nodes with standard deviation
+// Sigma mapped to the range (-1, 1], i.e., it promotes links between close nodes and suppresses links between distant
+// ones. The weight is produced by Mapping if set, or scaled to fit WeightRange otherwise.
+type GaussianLocalityLeoLinkExpression struct {
+	// Sigma The standard deviation of the Gaussian
+	Sigma float64
+	// ExpressionThreshold The threshold value seeded LEO output should exceed
+	ExpressionThreshold float64
+	// WeightRange The range of the link weights
+	WeightRange float64
+	// Mapping The optional mapping of the CPPN weight output into the link weight
+	Mapping WeightMapping
+}
+
+func (g GaussianLocalityLeoLinkExpression) ExpressLink(weight, leo float64, source, target PointF) (float64, bool) {
+	dx, dy, dz := target.X-source.X, target.Y-source.Y, target.Z-source.Z
+	locality := math.Exp(-(dx*dx + dy*dy + dz*dz) / (2 * g.Sigma * g.Sigma))
+	if leo+2*locality-1 > g.ExpressionThreshold {
+		if g.Mapping != nil {
+			return g.Mapping.MapWeight(weight), true
+		}
+		return LinearWeightMapping{WeightRange: g.WeightRange}.MapWeight(weight), true
+	}
+	return 0, false
+}
+
+func (g GaussianLocalityLeoLinkExpression) UsesLeo() bool {
+	return true
+}
+
+// NewLinkExpressionStrategy Creates the link expression strategy defined by provided options. If the type of
+// strategy is not set, the LEO strategy is used when LEO is enabled, and the weight threshold strategy otherwise. If
+// the weight mapping is not set by options, the default weight mapping of the strategy with WeightRange of options is
+// used.
+func NewLinkExpressionStrategy(options *hyperneat.Options) (LinkExpressionStrategy, error) {
+	return newLinkExpressionStrategy(options, options.LeoEnabled)
+}
+
+func newLinkExpressionStrategy(options *hyperneat.Options, useLeo bool) (LinkExpressionStrategy, error) {
+	expression := options.LinkExpression
+	if expression == "" {
+		if useLeo {
+			expression = hyperneat.LinkExpressionLeo
+		} else {
+			expression = hyperneat.LinkExpressionThreshold
+		}
+	}
+	// the weight mapping defined by options, nil if the default mapping of the strategy should be used
+	mapping, err := NewWeightMapping(options)
+	if err != nil {
+		return nil, err
+	}
+
+	switch expression {
+	case hyperneat.LinkExpressionThreshold:
+		return ThresholdLinkExpression{
+			Threshold:   options.LinkThreshold,
+			WeightRange: options.WeightRange,
+			Mapping:     mapping,
+		}, nil
+	case hyperneat.LinkExpressionLeo:
+		return LeoLinkExpression{
+			ExpressionThreshold: options.LeoThreshold,
+			WeightRange:         options.WeightRange,
+			Mapping:             mapping,
+		}, nil
+	case hyperneat.LinkExpressionLeoThreshold:
+		return LeoThresholdLinkExpression{
+			ExpressionThreshold: options.LeoThreshold,
+			WeightThreshold:     options.LinkThreshold,
+			WeightRange:         options.WeightRange,
+			Mapping:             mapping,
+		}, nil
+	case hyperneat.LinkExpressionLeoGaussianLocality:
+		if options.LeoLocalitySigma <= 0 {
+			return nil, errors.Errorf("LEO locality sigma must be positive, got: %f", options.LeoLocalitySigma)
+		}
+		return GaussianLocalityLeoLinkExpression{
+			Sigma:               options.LeoLocalitySigma,
+			ExpressionThreshold: options.LeoThreshold,
+			WeightRange:         options.WeightRange,
+			Mapping:             mapping,
+		}, nil
+	default:
+		return nil, errors.Errorf("unsupported link expression strategy: %s", expression)
+	}
+}
+
+// Returns the LEO output of the CPPN if present. If CPPN has no LEO output the link is enabled unconditionally.
+func cppnLeo(outs []float64) float64 {
+	if len(outs) > 1 {
+		return outs[1]
+	}
+	return 1.0
+}
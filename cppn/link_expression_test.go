@@ -0,0 +1,197 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
+	"github.com/yaricom/goNEAT/v4/neat/math"
+	"testing"
+)
+
+func TestLinkExpressionStrategies(t *testing.T) {
+	source := PointF{X: 0.0, Y: -1.0}
+	near := PointF{X: 0.0, Y: -0.9}
+	far := PointF{X: 0.0, Y: 1.0}
+
+	testCases := []struct {
+		name       string
+		strategy   LinkExpressionStrategy
+		weight     float64
+		leo        float64
+		target     PointF
+		expressed  bool
+		expWeight  float64
+		expUsesLeo bool
+	}{
+		{
+			name:      "threshold expressed",
+			strategy:  ThresholdLinkExpression{Threshold: 0.2, WeightRange: 3.0},
+			weight:    -0.6,
+			leo:       -1.0,
+			target:    far,
+			expressed: true,
+			expWeight: -1.5,
+		},
+		{
+			name:     "threshold suppressed",
+			strategy: ThresholdLinkExpression{Threshold: 0.2, WeightRange: 3.0},
+			weight:   0.1,
+			leo:      1.0,
+			target:   far,
+		},
+		{
+			name:       "LEO expressed",
+			strategy:   LeoLinkExpression{WeightRange: 3.0},
+			weight:     0.1,
+			leo:        0.01,
+			target:     far,
+			expressed:  true,
+			expWeight:  0.3,
+			expUsesLeo: true,
+		},
+		{
+			name:       "LEO below expression threshold",
+			strategy:   LeoLinkExpression{ExpressionThreshold: 0.5, WeightRange: 3.0},
+			weight:     0.1,
+			leo:        0.4,
+			target:     far,
+			expUsesLeo: true,
+		},
+		{
+			name:       "LEO with weight threshold expressed",
+			strategy:   LeoThresholdLinkExpression{WeightThreshold: 0.2, WeightRange: 3.0},
+			weight:     0.6,
+			leo:        0.1,
+			target:     far,
+			expressed:  true,
+			expWeight:  1.5,
+			expUsesLeo: true,
+		},
+		{
+			name:       "LEO with weight threshold suppressed by weight",
+			strategy:   LeoThresholdLinkExpression{WeightThreshold: 0.2, WeightRange: 3.0},
+			weight:     0.1,
+			leo:        0.1,
+			target:     far,
+			expUsesLeo: true,
+		},
+		{
+			name:       "Gaussian locality LEO expressed for near nodes",
+			strategy:   GaussianLocalityLeoLinkExpression{Sigma: 0.5, WeightRange: 3.0},
+			weight:     0.1,
+			leo:        -0.5,
+			target:     near,
+			expressed:  true,
+			expWeight:  0.3,
+			expUsesLeo: true,
+		},
+		{
+			name:       "Gaussian locality LEO suppressed for far nodes",
+			strategy:   GaussianLocalityLeoLinkExpression{Sigma: 0.5, WeightRange: 3.0},
+			weight:     0.1,
+			leo:        0.5,
+			target:     far,
+			expUsesLeo: true,
+		},
+		{
+			name:      "threshold with mapping expressed",
+			strategy:  ThresholdLinkExpression{Threshold: 0.2, WeightRange: 3.0, Mapping: LinearWeightMapping{WeightRange: 2.0}},
+			weight:    -0.6,
+			leo:       -1.0,
+			target:    far,
+			expressed: true,
+			expWeight: -1.2,
+		},
+		{
+			name:       "LEO with mapping expressed",
+			strategy:   LeoLinkExpression{WeightRange: 3.0, Mapping: LinearWeightMapping{WeightRange: 2.0}},
+			weight:     0.5,
+			leo:        0.01,
+			target:     far,
+			expressed:  true,
+			expWeight:  1.0,
+			expUsesLeo: true,
+		},
+		{
+			name:       "zero value LEO expressed",
+			strategy:   LeoLinkExpression{},
+			weight:     0.5,
+			leo:        0.01,
+			target:     far,
+			expressed:  true,
+			expUsesLeo: true,
+		},
+		{
+			name:      "zero value threshold expressed",
+			strategy:  ThresholdLinkExpression{},
+			weight:    0.5,
+			leo:       -1.0,
+			target:    far,
+			expressed: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			weight, ok := tc.strategy.ExpressLink(tc.weight, tc.leo, source, tc.target)
+			assert.Equal(t, tc.expressed, ok, "wrong link expression")
+			assert.InDelta(t, tc.expWeight, weight, 1e-12, "wrong link weight")
+			assert.Equal(t, tc.expUsesLeo, tc.strategy.UsesLeo())
+		})
+	}
+}
+
+func TestNewLinkExpressionStrategy(t *testing.T) {
+	options := &hyperneat.Options{LinkThreshold: 0.2, WeightRange: 3.0, LeoThreshold: 0.1, LeoLocalitySigma: 0.5}
+
+	strategy, err := NewLinkExpressionStrategy(options)
+	require.NoError(t, err)
+	assert.Equal(t, ThresholdLinkExpression{Threshold: 0.2, WeightRange: 3.0}, strategy)
+
+	options.LeoEnabled = true
+	strategy, err = NewLinkExpressionStrategy(options)
+	require.NoError(t, err)
+	assert.Equal(t, LeoLinkExpression{ExpressionThreshold: 0.1, WeightRange: 3.0}, strategy)
+
+	options.LinkExpression = hyperneat.LinkExpressionLeoThreshold
+	strategy, err = NewLinkExpressionStrategy(options)
+	require.NoError(t, err)
+	assert.Equal(t, LeoThresholdLinkExpression{ExpressionThreshold: 0.1, WeightThreshold: 0.2, WeightRange: 3.0}, strategy)
+
+	options.LinkExpression = hyperneat.LinkExpressionLeoGaussianLocality
+	strategy, err = NewLinkExpressionStrategy(options)
+	require.NoError(t, err)
+	assert.Equal(t, GaussianLocalityLeoLinkExpression{Sigma: 0.5, ExpressionThreshold: 0.1, WeightRange: 3.0}, strategy)
+
+	options.LeoLocalitySigma = 0
+	_, err = NewLinkExpressionStrategy(options)
+	assert.EqualError(t, err, "LEO locality sigma must be positive, got: 0.000000")
+
+	options.LinkExpression = "unknown"
+	_, err = NewLinkExpressionStrategy(options)
+	assert.EqualError(t, err, "unsupported link expression strategy: unknown")
+}
+
+func TestSubstrate_CreateNetworkSolver_LinkExpression(t *testing.T) {
+	layout := NewGridSubstrateLayout(1, 4, 2, 2)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := FastSolverFromGenomeFile(cppnLeoHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+
+	leoSolver, err := substr.CreateNetworkSolver(cppn, true, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+
+	// the weight threshold can only suppress links expressed by LEO
+	context.LinkExpression = hyperneat.LinkExpressionLeoThreshold
+	solver, err := substr.CreateNetworkSolver(cppn, true, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+	assert.True(t, solver.LinkCount() <= leoSolver.LinkCount(), "LEO with threshold expressed more links than LEO")
+
+	// the substrate strategy overrides options
+	substr.LinkExpression = LeoLinkExpression{WeightRange: context.WeightRange}
+	solver, err = substr.CreateNetworkSolver(cppn, false, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+	assert.Equal(t, leoSolver.LinkCount(), solver.LinkCount())
+}
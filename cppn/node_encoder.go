@@ -0,0 +1,139 @@
+package cppn
+
+import (
+	"context"
+	"fmt"
+	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
+	neatmath "github.com/yaricom/goNEAT/v4/neat/math"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+)
+
+// nodeEncoder queries CPPN at the substrate node positions to encode the node properties, such as bias and
+// activation function. The CPPN is queried with the node position as a source and the origin as a target.
+type nodeEncoder struct {
+	// The CPPN network solver to describe the geometry of substrate
+	cppn network.Solver
+	// The encoder of the hypercube coordinates into the CPPN inputs
+	encoder CoordinateEncoder
+	// The HyperNEAT options
+	options *hyperneat.Options
+	// The tracker of resources consumed against the limits, nil if queries are not limited
+	limits *resourceLimits
+
+	// The CPPN outputs queried for the substrate nodes by node index
+	outputs map[int][]float64
+}
+
+// Creates new node encoder with given CPPN and options. The CPPN queries are counted against provided resource
+// limits unless they are nil.
+func newNodeEncoder(cppn network.Solver, encoder CoordinateEncoder, options *hyperneat.Options, limits *resourceLimits) *nodeEncoder {
+	return &nodeEncoder{
+		cppn:    cppn,
+		encoder: encoder,
+		options: options,
+		limits:  limits,
+		outputs: make(map[int][]float64),
+	}
+}
+
+// Returns true if any node property should be encoded by CPPN
+func (n *nodeEncoder) enabled() bool {
+	return n.biasEnabled() || n.activationEnabled() || n.modulationEnabled()
+}
+
+// Returns true if node biases should be encoded by CPPN
+func (n *nodeEncoder) biasEnabled() bool {
+	return n.options.NodeBiasEnabled
+}
+
+// Returns true if node activation functions should be selected by CPPN
+func (n *nodeEncoder) activationEnabled() bool {
+	return len(n.options.NodeActivatorsPalette.ActivationTypes) > 0
+}
+
+// Returns true if CPPN should decide whether nodes are modulatory
+func (n *nodeEncoder) modulationEnabled() bool {
+	return n.options.NeuromodulationEnabled
+}
+
+// Reserves the CPPN queries for the given number of nodes within the resource limits if node properties should be
+// encoded. Returns false if the limit exceeded under truncate policy, or the resource limit error otherwise.
+func (n *nodeEncoder) reserve(count int) (bool, error) {
+	if !n.enabled() || n.limits == nil || count == 0 {
+		return true, nil
+	}
+	return n.limits.reserveCppnQueries(count)
+}
+
+// Queries CPPN for the nodes with given indexes at provided positions and stores outputs. The queries should be
+// reserved in advance. Returns error if CPPN query failed, the context is done, or CPPN has not enough outputs to
+// encode node properties.
+func (n *nodeEncoder) query(ctx context.Context, indexes []int, positions []*PointF) error {
+	if !n.enabled() || len(indexes) == 0 {
+		return nil
+	}
+	coordinates := make([][]float64, len(positions))
+	for i, position := range positions {
+		coordinates[i] = encodeCoordinates(n.encoder, *position, PointF{})
+	}
+	outputs, err := QueryCPPNBatchContext(ctx, coordinates, n.cppn)
+	if err != nil {
+		return err
+	}
+	for i, outs := range outputs {
+		if n.biasEnabled() && (n.options.NodeBiasOutput < 0 || n.options.NodeBiasOutput >= len(outs)) {
+			return fmt.Errorf("node bias output index [%d] is out of CPPN outputs range [%d]",
+				n.options.NodeBiasOutput, len(outs))
+		}
+		if n.activationEnabled() {
+			first := n.options.NodeActivatorsOutput
+			last := first + len(n.options.NodeActivatorsPalette.ActivationTypes)
+			if first < 0 || last > len(outs) {
+				return fmt.Errorf("node activators outputs [%d, %d) are out of CPPN outputs range [%d]",
+					first, last, len(outs))
+			}
+		}
+		if n.modulationEnabled() && (n.options.NeuromodulationOutput < 0 || n.options.NeuromodulationOutput >= len(outs)) {
+			return fmt.Errorf("neuromodulation output index [%d] is out of CPPN outputs range [%d]",
+				n.options.NeuromodulationOutput, len(outs))
+		}
+		n.outputs[indexes[i]] = outs
+	}
+	return nil
+}
+
+// Returns the bias of the node with given index or zero if node was not queried
+func (n *nodeEncoder) bias(index int) float64 {
+	if outs, ok := n.outputs[index]; ok && n.biasEnabled() {
+		return outs[n.options.NodeBiasOutput] * n.options.WeightRange
+	}
+	return 0.0
+}
+
+// Returns the activation function of the node with given index selected by CPPN from the palette of activators. The
+// activator corresponding to the CPPN output with maximal value is selected. If node was not queried the provided
+// default activation function returned.
+func (n *nodeEncoder) activation(index int, defaultActivation neatmath.NodeActivationType) neatmath.NodeActivationType {
+	outs, ok := n.outputs[index]
+	if !ok || !n.activationEnabled() {
+		return defaultActivation
+	}
+	palette := n.options.NodeActivatorsPalette.ActivationTypes
+	selected := 0
+	for i := 1; i < len(palette); i++ {
+		if outs[n.options.NodeActivatorsOutput+i] > outs[n.options.NodeActivatorsOutput+selected] {
+			selected = i
+		}
+	}
+	return palette[selected]
+}
+
+// Returns the function of the node with given index decided by CPPN. The node is modulatory if the CPPN output exceeds
+// the neuromodulation threshold. If node was not queried the standard function returned.
+func (n *nodeEncoder) function(index int) NodeFunctionType {
+	if outs, ok := n.outputs[index]; ok && n.modulationEnabled() &&
+		outs[n.options.NeuromodulationOutput] > n.options.NeuromodulationThreshold {
+		return NodeFunctionModulatory
+	}
+	return NodeFunctionStandard
+}
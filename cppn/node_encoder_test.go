@@ -0,0 +1,50 @@
+package cppn
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
+	"testing"
+)
+
+func TestNodeEncoder_Reserve(t *testing.T) {
+	limits, err := newResourceLimits(&eshyperneat.Options{MaxCppnQueries: 2})
+	require.NoError(t, err, "failed to create resource limits")
+
+	// the queries are not reserved if node properties are not encoded
+	nodes := newNodeEncoder(nil, nil, &hyperneat.Options{}, limits)
+	ok, err := nodes.reserve(5)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	// the queries are reserved within the limit
+	nodes = newNodeEncoder(nil, nil, &hyperneat.Options{NodeBiasEnabled: true}, limits)
+	ok, err = nodes.reserve(2)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	ok, err = nodes.reserve(1)
+	assert.False(t, ok)
+	var limitErr *ResourceLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, ResourceCppnQueries, limitErr.Resource)
+
+	// the queries are not limited without resource limits
+	nodes = newNodeEncoder(nil, nil, &hyperneat.Options{NodeBiasEnabled: true}, nil)
+	ok, err = nodes.reserve(5)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+}
+
+func TestNodeEncoder_QueryCancelled(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	ctx, cancel := context.WithCancel(context.Background())
+	solver := &cancellingSolver{Solver: cppn, cancel: cancel, after: 1}
+
+	nodes := newNodeEncoder(solver, NewCoordinateEncoder(false), &hyperneat.Options{NodeBiasEnabled: true}, nil)
+	err = nodes.query(ctx, []int{0, 1}, []*PointF{{X: -0.5, Y: 1.0}, {X: 0.5, Y: 1.0}})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, nodes.outputs, "no node outputs expected")
+}
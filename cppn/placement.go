@@ -0,0 +1,48 @@
+package cppn
+
+import "github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+
+// PlacementConstraint Defines where the hidden nodes can be placed within the evolvable substrate. The connections
+// to the points where hidden nodes are not allowed are dropped.
+type PlacementConstraint interface {
+	// Allowed Returns true if the hidden node can be placed at the given position
+	Allowed(position PointF) bool
+}
+
+// RegionPlacementConstraint Allows hidden nodes within any of the allowed regions and outside all forbidden regions.
+// If no allowed regions defined, the hidden nodes are allowed anywhere outside the forbidden regions.
+type RegionPlacementConstraint struct {
+	// AllowedRegions The regions where hidden nodes can be placed
+	AllowedRegions []eshyperneat.Region
+	// ForbiddenRegions The regions where hidden nodes can not be placed
+	ForbiddenRegions []eshyperneat.Region
+}
+
+func (r RegionPlacementConstraint) Allowed(position PointF) bool {
+	for _, region := range r.ForbiddenRegions {
+		if region.Contains(position.X, position.Y, position.Z) {
+			return false
+		}
+	}
+	if len(r.AllowedRegions) == 0 {
+		return true
+	}
+	for _, region := range r.AllowedRegions {
+		if region.Contains(position.X, position.Y, position.Z) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPlacementConstraint Creates the placement constraint of the hidden nodes defined by provided options. Returns nil
+// if no allowed or forbidden regions are defined, i.e., the hidden nodes can be placed anywhere.
+func NewPlacementConstraint(options *eshyperneat.Options) PlacementConstraint {
+	if len(options.HiddenAllowedRegions) == 0 && len(options.HiddenForbiddenRegions) == 0 {
+		return nil
+	}
+	return RegionPlacementConstraint{
+		AllowedRegions:   options.HiddenAllowedRegions,
+		ForbiddenRegions: options.HiddenForbiddenRegions,
+	}
+}
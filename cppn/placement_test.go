@@ -0,0 +1,80 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"github.com/yaricom/goNEAT/v4/neat/math"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"testing"
+)
+
+func TestRegionPlacementConstraint_Allowed(t *testing.T) {
+	// forbid input and output rows and allow the band in the middle of the substrate with hole
+	constraint := RegionPlacementConstraint{
+		AllowedRegions: []eshyperneat.Region{{MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: 0.5}},
+		ForbiddenRegions: []eshyperneat.Region{
+			{MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: -1.0},
+			{MinX: -0.25, MaxX: 0.25, MinY: -0.25, MaxY: 0.25},
+		},
+	}
+	testCases := []struct {
+		position PointF
+		expected bool
+	}{
+		{position: PointF{X: 0.5, Y: 0.0}, expected: true},
+		{position: PointF{X: -1.0, Y: 0.5}, expected: true},
+		{position: PointF{X: 0.5, Y: -1.0}, expected: false},
+		{position: PointF{X: 0.0, Y: 0.0}, expected: false},
+		{position: PointF{X: 0.5, Y: 0.75}, expected: false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, constraint.Allowed(tc.position), "wrong result at: %s", &tc.position)
+	}
+
+	// only forbidden regions
+	constraint.AllowedRegions = nil
+	assert.True(t, constraint.Allowed(PointF{X: 0.5, Y: 0.75}))
+}
+
+func TestNewPlacementConstraint(t *testing.T) {
+	assert.Nil(t, NewPlacementConstraint(&eshyperneat.Options{}))
+
+	forbidden := []eshyperneat.Region{{MinX: -1.0, MaxX: 1.0, MinY: 1.0, MaxY: 1.0}}
+	constraint := NewPlacementConstraint(&eshyperneat.Options{HiddenForbiddenRegions: forbidden})
+	assert.Equal(t, RegionPlacementConstraint{ForbiddenRegions: forbidden}, constraint)
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_Placement(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	// the placement constraint defined by options
+	forbidden := eshyperneat.Region{MinX: -1.0, MaxX: 0.0, MinY: -1.0, MaxY: 1.0}
+	context.HiddenForbiddenRegions = []eshyperneat.Region{forbidden}
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	require.True(t, layout.HiddenCount() > 0, "no hidden nodes discovered")
+	for i := 0; i < layout.HiddenCount(); i++ {
+		position, err := layout.NodePosition(i, network.HiddenNeuron)
+		require.NoError(t, err, "failed to get hidden node position")
+		assert.False(t, forbidden.Contains(position.X, position.Y, position.Z), "hidden node in forbidden region: %s", position)
+	}
+
+	// the custom placement constraint takes precedence over options and connections to disallowed points are dropped
+	layout, err = NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr = NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	substr.Placement = RegionPlacementConstraint{
+		ForbiddenRegions: []eshyperneat.Region{{MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: 1.0}},
+	}
+	solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, 0, layout.HiddenCount(), "no hidden nodes expected")
+	assert.Equal(t, 0, solver.LinkCount(), "no links expected")
+}
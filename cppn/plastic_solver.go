@@ -0,0 +1,393 @@
+package cppn
+
+import (
+	"errors"
+	"fmt"
+	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
+	neatmath "github.com/yaricom/goNEAT/v4/neat/math"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"math"
+)
+
+// NodeFunctionType The function of the substrate neuron in the network
+type NodeFunctionType string
+
+const (
+	// NodeFunctionStandard The neuron contributes its activation to the activation of its targets
+	NodeFunctionStandard NodeFunctionType = "standard"
+	// NodeFunctionModulatory The neuron does not contribute to the activation of its targets, but scales the Hebbian
+	// weights updates of the incoming links of its targets
+	NodeFunctionModulatory NodeFunctionType = "modulatory"
+)
+
+// HebbianRule The coefficients of the generalized Hebbian learning rule of the plastic link. After each activation step
+// the link weight is changed by: Δw = LearningRate·(A·pre·post + B·pre + C·post + D), where pre and post are the
+// activations of the source and target neurons.
+type HebbianRule struct {
+	// A The correlation term coefficient
+	A float64
+	// B The presynaptic term coefficient
+	B float64
+	// C The postsynaptic term coefficient
+	C float64
+	// D The constant term
+	D float64
+	// LearningRate The learning rate of the link
+	LearningRate float64
+}
+
+// Delta Returns the change of the link weight for given activations of the source and target neurons
+func (r HebbianRule) Delta(pre, post float64) float64 {
+	return r.LearningRate * (r.A*pre*post + r.B*pre + r.C*post + r.D)
+}
+
+func (r HebbianRule) String() string {
+	return fmt.Sprintf("A: %f, B: %f, C: %f, D: %f, learning rate: %f", r.A, r.B, r.C, r.D, r.LearningRate)
+}
+
+// Creates the Hebbian learning rule from the CPPN outputs queried for a link. Returns error if CPPN has not enough
+// outputs to encode the learning rule.
+func newHebbianRule(outputs []float64, options *hyperneat.Options) (HebbianRule, error) {
+	first := options.PlasticityOutput
+	last := first + 5
+	if first < 0 || last > len(outputs) {
+		return HebbianRule{}, fmt.Errorf("plasticity outputs [%d, %d) are out of CPPN outputs range [%d]",
+			first, last, len(outputs))
+	}
+	return HebbianRule{
+		A:            outputs[first],
+		B:            outputs[first+1],
+		C:            outputs[first+2],
+		D:            outputs[first+3],
+		LearningRate: outputs[first+4],
+	}, nil
+}
+
+// Checks that plasticity related options are consistent
+func validatePlasticityOptions(options *hyperneat.Options) error {
+	if options.NeuromodulationEnabled && !options.PlasticityEnabled {
+		return errors.New("neuromodulation requires plasticity to be enabled")
+	}
+	return nil
+}
+
+// PlasticNetworkSolver The network solver with plastic links which weights are updated online according to the
+// Hebbian learning rule of each link after every forward activation step. The neurons are ordered in the same way as
+// in the FastModularNetworkSolver: bias, input, output, hidden.
+//
+// If the network has modulatory neurons, it performs the modulated plasticity. The signals of the modulatory neurons
+// are summed separately for each target neuron into its modulation m, and the weights updates of the incoming links
+// of the target are scaled by tanh(m/2). Thus, without modulatory input the links of the target are not updated.
+// The links from modulatory neurons are not plastic.
+type PlasticNetworkSolver struct {
+	// The current activation values per each neuron
+	neuronSignals []float64
+	// This array is a parallel of neuronSignals and used to test network relaxation
+	neuronSignalsBeingProcessed []float64
+	// The modulatory signals received by each neuron during the last activation step
+	neuronModulation []float64
+
+	// The activation functions per neuron
+	activationFunctions []neatmath.NodeActivationType
+	// The functions per neuron, nil if network has no modulatory neurons
+	nodeFunctions []NodeFunctionType
+	// The bias values associated with neurons
+	biasList []float64
+	// The plastic connections
+	connections []*network.FastNetworkLink
+	// The learning rules of connections
+	rules []HebbianRule
+	// The initial weights of connections
+	initialWeights []float64
+	// The maximal absolute value of the connection weight, zero if weights are not limited
+	weightRange float64
+
+	// The number of input neurons
+	inputNeuronCount int
+	// The total number of sensors in the network (input + bias)
+	sensorNeuronCount int
+	// The number of output neurons
+	outputNeuronCount int
+	// The bias neuron count (usually one)
+	biasNeuronCount int
+	// The total number of neurons in network
+	totalNeuronCount int
+	// The number of forward steps to propagate the sensors signals to all neurons reachable from them
+	depth int
+}
+
+// NewPlasticNetworkSolver Creates new plastic network solver. The learning rules must be provided for each connection
+// in the same order. The weights of connections are kept within [-weightRange, weightRange] during learning unless
+// the weight range is zero.
+func NewPlasticNetworkSolver(biasNeuronCount, inputNeuronCount, outputNeuronCount, totalNeuronCount int,
+	activationFunctions []neatmath.NodeActivationType, connections []*network.FastNetworkLink, rules []HebbianRule,
+	biasList []float64, weightRange float64) (*PlasticNetworkSolver, error) {
+	return NewModulatedPlasticNetworkSolver(biasNeuronCount, inputNeuronCount, outputNeuronCount, totalNeuronCount,
+		activationFunctions, nil, connections, rules, biasList, weightRange)
+}
+
+// NewModulatedPlasticNetworkSolver Creates new plastic network solver with modulatory neurons defined by the provided
+// functions of neurons. If functions are not provided, the plain Hebbian plasticity is performed.
+func NewModulatedPlasticNetworkSolver(biasNeuronCount, inputNeuronCount, outputNeuronCount, totalNeuronCount int,
+	activationFunctions []neatmath.NodeActivationType, nodeFunctions []NodeFunctionType,
+	connections []*network.FastNetworkLink, rules []HebbianRule, biasList []float64,
+	weightRange float64) (*PlasticNetworkSolver, error) {
+	if len(rules) != len(connections) {
+		return nil, fmt.Errorf("the number of learning rules [%d] does not match the number of connections [%d]",
+			len(rules), len(connections))
+	}
+	if len(activationFunctions) != totalNeuronCount {
+		return nil, fmt.Errorf("the number of activation functions [%d] does not match the number of neurons [%d]",
+			len(activationFunctions), totalNeuronCount)
+	}
+	if nodeFunctions != nil && len(nodeFunctions) != totalNeuronCount {
+		return nil, fmt.Errorf("the number of node functions [%d] does not match the number of neurons [%d]",
+			len(nodeFunctions), totalNeuronCount)
+	}
+	solver := PlasticNetworkSolver{
+		biasNeuronCount:     biasNeuronCount,
+		inputNeuronCount:    inputNeuronCount,
+		sensorNeuronCount:   biasNeuronCount + inputNeuronCount,
+		outputNeuronCount:   outputNeuronCount,
+		totalNeuronCount:    totalNeuronCount,
+		activationFunctions: activationFunctions,
+		nodeFunctions:       nodeFunctions,
+		biasList:            biasList,
+		connections:         connections,
+		rules:               rules,
+		weightRange:         weightRange,
+	}
+	solver.initialWeights = make([]float64, len(connections))
+	for i, conn := range connections {
+		solver.initialWeights[i] = conn.Weight
+	}
+
+	// Allocate the arrays that store the states at different points in the neural network.
+	solver.neuronSignals = make([]float64, totalNeuronCount)
+	solver.neuronSignalsBeingProcessed = make([]float64, totalNeuronCount)
+	solver.neuronModulation = make([]float64, totalNeuronCount)
+	for i := 0; i < biasNeuronCount; i++ {
+		solver.neuronSignals[i] = 1.0 // BIAS neuron signal
+	}
+	solver.depth = solver.networkDepth()
+	return &solver, nil
+}
+
+// Returns the length of the longest path from sensors to any neuron reachable from them. The depth of the network with
+// recurrent loops is limited by the number of non-sensor neurons. The depth is at least one to activate the outputs
+// linked directly to the sensors.
+func (s *PlasticNetworkSolver) networkDepth() int {
+	depths := make([]int, s.totalNeuronCount)
+	for i := range depths {
+		if i >= s.sensorNeuronCount {
+			depths[i] = -1 // not reachable yet
+		}
+	}
+	maxDepth := s.totalNeuronCount - s.sensorNeuronCount
+	depth := 1
+	for changed := true; changed; {
+		changed = false
+		for _, conn := range s.connections {
+			if conn.TargetIndex < s.sensorNeuronCount || depths[conn.SourceIndex] < 0 {
+				continue
+			}
+			if next := depths[conn.SourceIndex] + 1; next > depths[conn.TargetIndex] && next <= maxDepth {
+				depths[conn.TargetIndex] = next
+				if next > depth {
+					depth = next
+				}
+				changed = true
+			}
+		}
+	}
+	return depth
+}
+
+// ForwardSteps Propagates activation wave through all network nodes provided number of steps in forward direction
+// and updates weights of connections after each step.
+func (s *PlasticNetworkSolver) ForwardSteps(steps int) (res bool, err error) {
+	for i := 0; i < steps; i++ {
+		if res, err = s.forwardStep(0); err != nil {
+			return false, err
+		}
+	}
+	return res, nil
+}
+
+// RecursiveSteps Propagates activation wave from sensors through all network nodes reachable from them, i.e., performs
+// ForwardSteps with the number of steps equal to the depth of the network. The weights of connections are updated
+// after each step as in ForwardSteps.
+func (s *PlasticNetworkSolver) RecursiveSteps() (bool, error) {
+	return s.ForwardSteps(s.depth)
+}
+
+// Relax Attempts to relax network given amount of steps until giving up. The network considered relaxed when the
+// absolute value of the change at any given point is less than maxAllowedSignalDelta during activation waves
+// propagation. The weights of connections are updated after each step.
+func (s *PlasticNetworkSolver) Relax(maxSteps int, maxAllowedSignalDelta float64) (relaxed bool, err error) {
+	for i := 0; i < maxSteps; i++ {
+		if relaxed, err = s.forwardStep(maxAllowedSignalDelta); err != nil {
+			return false, err
+		} else if relaxed {
+			break // no need to iterate any further, already reached desired accuracy
+		}
+	}
+	return relaxed, nil
+}
+
+// Performs single forward step through the network and updates weights of connections. Returns true if network
+// relaxed, i.e., no neuron signal changed more than maxAllowedSignalDelta.
+func (s *PlasticNetworkSolver) forwardStep(maxAllowedSignalDelta float64) (isRelaxed bool, err error) {
+	isRelaxed = true
+
+	// Calculate output signal per each connection and add the signals to the target neurons, the signals of modulatory
+	// neurons are collected separately
+	for i := range s.neuronModulation {
+		s.neuronModulation[i] = 0
+	}
+	for _, conn := range s.connections {
+		signal := s.neuronSignals[conn.SourceIndex] * conn.Weight
+		if s.isModulatory(conn.SourceIndex) {
+			s.neuronModulation[conn.TargetIndex] += signal
+		} else {
+			s.neuronSignalsBeingProcessed[conn.TargetIndex] += signal
+		}
+	}
+
+	// Pass the signals through the single-valued activation functions
+	for i := s.sensorNeuronCount; i < s.totalNeuronCount; i++ {
+		signal := s.neuronSignalsBeingProcessed[i]
+		if s.biasNeuronCount > 0 && len(s.biasList) > 0 {
+			// append BIAS value if BIAS neuron is present
+			signal += s.biasList[i]
+		}
+		if s.neuronSignalsBeingProcessed[i], err = neatmath.NodeActivators.ActivateByType(
+			signal, nil, s.activationFunctions[i]); err != nil {
+			return false, err
+		}
+	}
+
+	// Move all the neuron signals we changed while processing this network activation into storage.
+	for i := s.sensorNeuronCount; i < s.totalNeuronCount; i++ {
+		if maxAllowedSignalDelta > 0 {
+			// Check whether the change in the neuron signal is small enough to consider network relaxed
+			isRelaxed = isRelaxed && !(math.Abs(s.neuronSignals[i]-s.neuronSignalsBeingProcessed[i]) > maxAllowedSignalDelta)
+		}
+		s.neuronSignals[i] = s.neuronSignalsBeingProcessed[i]
+		s.neuronSignalsBeingProcessed[i] = 0
+	}
+
+	// Update weights of connections according to the learning rules
+	s.updateWeights()
+
+	return isRelaxed, err
+}
+
+// Updates weights of connections using current activations of the source and target neurons. If network has modulatory
+// neurons, the updates are scaled by the modulation of the target neurons.
+func (s *PlasticNetworkSolver) updateWeights() {
+	for i, conn := range s.connections {
+		if s.isModulatory(conn.SourceIndex) {
+			// the modulatory links are not plastic
+			continue
+		}
+		pre, post := s.neuronSignals[conn.SourceIndex], s.neuronSignals[conn.TargetIndex]
+		delta := s.rules[i].Delta(pre, post)
+		if s.nodeFunctions != nil {
+			delta *= math.Tanh(s.neuronModulation[conn.TargetIndex] / 2.0)
+		}
+		conn.Weight += delta
+		if s.weightRange > 0 {
+			conn.Weight = math.Max(-s.weightRange, math.Min(s.weightRange, conn.Weight))
+		}
+	}
+}
+
+// Flush Flushes network state by removing all current activations. The learned weights of connections are kept,
+// use ResetWeights to restore the initial weights.
+func (s *PlasticNetworkSolver) Flush() (bool, error) {
+	for i := s.biasNeuronCount; i < s.totalNeuronCount; i++ {
+		s.neuronSignals[i] = 0.0
+		s.neuronSignalsBeingProcessed[i] = 0.0
+		s.neuronModulation[i] = 0.0
+	}
+	return true, nil
+}
+
+// ResetWeights Restores the initial weights of connections encoded by CPPN, discarding everything learned.
+func (s *PlasticNetworkSolver) ResetWeights() {
+	for i, conn := range s.connections {
+		conn.Weight = s.initialWeights[i]
+	}
+}
+
+// Weights Returns the current weights of connections
+func (s *PlasticNetworkSolver) Weights() []float64 {
+	weights := make([]float64, len(s.connections))
+	for i, conn := range s.connections {
+		weights[i] = conn.Weight
+	}
+	return weights
+}
+
+// NodeFunctions Returns the functions of neurons, or nil if network has no modulatory neurons
+func (s *PlasticNetworkSolver) NodeFunctions() []NodeFunctionType {
+	return s.nodeFunctions
+}
+
+// Returns true if neuron with given index is modulatory
+func (s *PlasticNetworkSolver) isModulatory(index int) bool {
+	return s.nodeFunctions != nil && s.nodeFunctions[index] == NodeFunctionModulatory
+}
+
+// Rules Returns the learning rules of connections
+func (s *PlasticNetworkSolver) Rules() []HebbianRule {
+	return s.rules
+}
+
+// LoadSensors Set sensors values to the input nodes of the network
+func (s *PlasticNetworkSolver) LoadSensors(inputs []float64) error {
+	if len(inputs) == s.inputNeuronCount {
+		// only inputs should be provided
+		for i := 0; i < s.inputNeuronCount; i++ {
+			s.neuronSignals[s.biasNeuronCount+i] = inputs[i]
+		}
+	} else {
+		return network.ErrNetUnsupportedSensorsArraySize
+	}
+	return nil
+}
+
+// ReadOutputs Read output values from the output nodes of the network
+func (s *PlasticNetworkSolver) ReadOutputs() []float64 {
+	outs := make([]float64, s.outputNeuronCount)
+	copy(outs, s.neuronSignals[s.sensorNeuronCount:s.sensorNeuronCount+s.outputNeuronCount])
+	return outs
+}
+
+// NodeCount Returns the total number of neural units in the network
+func (s *PlasticNetworkSolver) NodeCount() int {
+	return s.totalNeuronCount
+}
+
+// LinkCount Returns the total number of links between nodes in the network
+func (s *PlasticNetworkSolver) LinkCount() int {
+	// count all connections
+	numLinks := len(s.connections)
+
+	// count all bias links if any
+	if s.biasNeuronCount > 0 {
+		for _, b := range s.biasList {
+			if b != 0 {
+				numLinks++
+			}
+		}
+	}
+	return numLinks
+}
+
+func (s *PlasticNetworkSolver) String() string {
+	return fmt.Sprintf("PlasticNetwork, neurons: %d,\n\tinputs: %d,\tbias: %d,\toutputs:%d,\t hidden: %d,\tlinks: %d",
+		s.totalNeuronCount, s.inputNeuronCount, s.biasNeuronCount, s.outputNeuronCount,
+		s.totalNeuronCount-s.sensorNeuronCount-s.outputNeuronCount, len(s.connections))
+}
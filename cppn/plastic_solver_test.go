@@ -0,0 +1,165 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
+	"github.com/yaricom/goNEAT/v4/neat/math"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"testing"
+)
+
+func TestHebbianRule_Delta(t *testing.T) {
+	rule := HebbianRule{A: 1.0, B: 0.5, C: -0.5, D: 0.1, LearningRate: 0.1}
+	assert.InDelta(t, 0.1*(1.0*2.0*3.0+0.5*2.0-0.5*3.0+0.1), rule.Delta(2.0, 3.0), 1e-15)
+	assert.Zero(t, HebbianRule{A: 1.0, B: 1.0, C: 1.0, D: 1.0}.Delta(2.0, 3.0), "zero learning rate")
+}
+
+func TestNewHebbianRule(t *testing.T) {
+	outputs := []float64{0.9, 0.1, 0.2, 0.3, 0.4, 0.5}
+	rule, err := newHebbianRule(outputs, &hyperneat.Options{PlasticityOutput: 1})
+	require.NoError(t, err, "failed to create learning rule")
+	assert.Equal(t, HebbianRule{A: 0.1, B: 0.2, C: 0.3, D: 0.4, LearningRate: 0.5}, rule)
+
+	_, err = newHebbianRule(outputs, &hyperneat.Options{PlasticityOutput: 2})
+	assert.EqualError(t, err, "plasticity outputs [2, 7) are out of CPPN outputs range [6]")
+	_, err = newHebbianRule(outputs, &hyperneat.Options{PlasticityOutput: -1})
+	assert.EqualError(t, err, "plasticity outputs [-1, 4) are out of CPPN outputs range [6]")
+}
+
+func TestNewPlasticNetworkSolver_Errors(t *testing.T) {
+	activations := []math.NodeActivationType{math.LinearActivation, math.LinearActivation}
+	links := []*network.FastNetworkLink{createLink(0.5, 0, 1)}
+
+	_, err := NewPlasticNetworkSolver(0, 1, 1, 2, activations, links, nil, nil, 0)
+	assert.EqualError(t, err, "the number of learning rules [0] does not match the number of connections [1]")
+	_, err = NewPlasticNetworkSolver(0, 1, 1, 3, activations, links, []HebbianRule{{}}, nil, 0)
+	assert.EqualError(t, err, "the number of activation functions [2] does not match the number of neurons [3]")
+}
+
+func TestPlasticNetworkSolver_ForwardSteps(t *testing.T) {
+	// the network with one input linked to one output, and bias of the output
+	activations := []math.NodeActivationType{math.LinearActivation, math.LinearActivation, math.LinearActivation}
+	links := []*network.FastNetworkLink{createLink(0.5, 1, 2)}
+	rules := []HebbianRule{{A: 1.0, LearningRate: 0.1}}
+	solver, err := NewPlasticNetworkSolver(1, 1, 1, 3, activations, links, rules, []float64{0, 0, 0.25}, 0.65)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, 3, solver.NodeCount())
+	assert.Equal(t, 2, solver.LinkCount())
+
+	err = solver.LoadSensors([]float64{1.0})
+	require.NoError(t, err, "failed to load sensors")
+
+	// the weight is increased by correlated activity of input and output
+	_, err = solver.ForwardSteps(1)
+	require.NoError(t, err, "failed to activate")
+	assert.InDelta(t, 0.75, solver.ReadOutputs()[0], 1e-15)
+	assert.InDelta(t, 0.575, solver.Weights()[0], 1e-15)
+
+	// the weight is limited by the weight range
+	_, err = solver.ForwardSteps(1)
+	require.NoError(t, err, "failed to activate")
+	assert.InDelta(t, 0.825, solver.ReadOutputs()[0], 1e-15)
+	assert.Equal(t, 0.65, solver.Weights()[0])
+
+	// the flush keeps learned weights, while reset restores initial weights
+	_, err = solver.Flush()
+	require.NoError(t, err, "failed to flush")
+	assert.Equal(t, []float64{0.0}, solver.ReadOutputs())
+	assert.Equal(t, 0.65, solver.Weights()[0])
+	solver.ResetWeights()
+	assert.Equal(t, []float64{0.5}, solver.Weights())
+
+	// the recursive activation of the single layer network is one forward step
+	require.NoError(t, solver.LoadSensors([]float64{1.0}))
+	_, err = solver.RecursiveSteps()
+	require.NoError(t, err, "failed to activate")
+	assert.InDelta(t, 0.75, solver.ReadOutputs()[0], 1e-15)
+	assert.InDelta(t, 0.575, solver.Weights()[0], 1e-15)
+
+	// the wrong number of sensors
+	err = solver.LoadSensors([]float64{1.0, 2.0})
+	assert.ErrorIs(t, err, network.ErrNetUnsupportedSensorsArraySize)
+}
+
+func TestPlasticNetworkSolver_RecursiveSteps(t *testing.T) {
+	// the network with input linked to output through the hidden node
+	activations := []math.NodeActivationType{math.LinearActivation, math.LinearActivation, math.LinearActivation}
+	links := []*network.FastNetworkLink{createLink(1.0, 0, 2), createLink(0.5, 2, 1)}
+	solver, err := NewPlasticNetworkSolver(0, 1, 1, 3, activations, links, []HebbianRule{{}, {}}, nil, 0)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, 2, solver.depth)
+
+	require.NoError(t, solver.LoadSensors([]float64{1.0}))
+	res, err := solver.RecursiveSteps()
+	require.NoError(t, err, "failed to activate")
+	assert.True(t, res)
+	assert.InDelta(t, 0.5, solver.ReadOutputs()[0], 1e-15)
+
+	// the recurrent links do not increase the depth beyond the number of non-sensor neurons
+	links = []*network.FastNetworkLink{createLink(1.0, 0, 2), createLink(0.5, 2, 1), createLink(0.1, 1, 2),
+		createLink(0.1, 2, 2)}
+	solver, err = NewPlasticNetworkSolver(0, 1, 1, 3, activations, links, []HebbianRule{{}, {}, {}, {}}, nil, 0)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, 2, solver.depth)
+
+	// the depth of the network without links from sensors is one
+	links = []*network.FastNetworkLink{createLink(1.0, 2, 1)}
+	solver, err = NewPlasticNetworkSolver(0, 1, 1, 3, activations, links, []HebbianRule{{}}, nil, 0)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, 1, solver.depth)
+}
+
+func TestPlasticNetworkSolver_Relax(t *testing.T) {
+	activations := []math.NodeActivationType{math.LinearActivation, math.LinearActivation}
+	links := []*network.FastNetworkLink{createLink(0.5, 0, 1)}
+
+	// the static weights relax network after the first step
+	solver, err := NewPlasticNetworkSolver(0, 1, 1, 2, activations, links, []HebbianRule{{}}, nil, 0)
+	require.NoError(t, err, "failed to create solver")
+	require.NoError(t, solver.LoadSensors([]float64{1.0}))
+	relaxed, err := solver.Relax(3, 0.01)
+	require.NoError(t, err, "failed to relax")
+	assert.True(t, relaxed)
+
+	// the constantly growing weight does not allow network to relax
+	links = []*network.FastNetworkLink{createLink(0.5, 0, 1)}
+	solver, err = NewPlasticNetworkSolver(0, 1, 1, 2, activations, links, []HebbianRule{{D: 1.0, LearningRate: 0.1}}, nil, 0)
+	require.NoError(t, err, "failed to create solver")
+	require.NoError(t, solver.LoadSensors([]float64{1.0}))
+	relaxed, err = solver.Relax(3, 0.01)
+	require.NoError(t, err, "failed to relax")
+	assert.False(t, relaxed)
+}
+
+func TestPlasticNetworkSolver_Modulation(t *testing.T) {
+	// the network with input linked to output and to modulatory hidden node, which is linked to output
+	activations := []math.NodeActivationType{math.LinearActivation, math.LinearActivation, math.LinearActivation}
+	functions := []NodeFunctionType{NodeFunctionStandard, NodeFunctionStandard, NodeFunctionModulatory}
+	links := []*network.FastNetworkLink{createLink(0.5, 0, 1), createLink(1.0, 0, 2), createLink(2.0, 2, 1)}
+	rules := []HebbianRule{{A: 1.0, LearningRate: 0.1}, {D: 1.0, LearningRate: 0.1}, {D: 1.0, LearningRate: 0.1}}
+	solver, err := NewModulatedPlasticNetworkSolver(0, 1, 1, 3, activations, functions, links, rules, nil, 0)
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, functions, solver.NodeFunctions())
+
+	err = solver.LoadSensors([]float64{1.0})
+	require.NoError(t, err, "failed to load sensors")
+
+	// no modulation received by output yet, thus no weights updates
+	_, err = solver.ForwardSteps(1)
+	require.NoError(t, err, "failed to activate")
+	assert.InDelta(t, 0.5, solver.ReadOutputs()[0], 1e-15)
+	assert.Equal(t, []float64{0.5, 1.0, 2.0}, solver.Weights())
+
+	// the modulatory signal scales weight update, but not contributes to activation of output
+	_, err = solver.ForwardSteps(1)
+	require.NoError(t, err, "failed to activate")
+	assert.InDelta(t, 0.5, solver.ReadOutputs()[0], 1e-15)
+	weights := solver.Weights()
+	assert.InDelta(t, 0.5+0.05*0.7615941559557649, weights[0], 1e-15)
+	// the modulatory node receives no modulation to update its incoming link, and the modulatory link is not plastic
+	assert.Equal(t, []float64{1.0, 2.0}, weights[1:])
+
+	_, err = NewModulatedPlasticNetworkSolver(0, 1, 1, 3, activations, functions[:2], links, rules, nil, 0)
+	assert.EqualError(t, err, "the number of node functions [2] does not match the number of neurons [3]")
+}
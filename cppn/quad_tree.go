@@ -23,6 +23,8 @@ type QuadPoint struct {
 	Weight float64
 	// Leo
 	Leo float64
+	// The CPPN outputs for this point
+	CppnOut []float64
 }
 
 func (q *QuadPoint) String() string {
@@ -41,7 +43,7 @@ func (q *QuadPoint) String() string {
 
 // NewQuadPoint Creates new quad point
 func NewQuadPoint(x1, y1, z1, x2, y2, z2 float64, node *QuadNode) *QuadPoint {
-	return &QuadPoint{X1: x1, Y1: y1, Z1: z1, X2: x2, Y2: y2, Z2: z2, Weight: node.Weight(), Leo: node.Leo()}
+	return &QuadPoint{X1: x1, Y1: y1, Z1: z1, X2: x2, Y2: y2, Z2: z2, Weight: node.Weight(), Leo: node.Leo(), CppnOut: node.CppnOut}
 }
 
 // QuadNode Defines quad-tree node to model 4 dimensional hypercube
@@ -52,6 +54,8 @@ type QuadNode struct {
 	Width float64
 	// The height of this quad-tree node's square
 	Height float64
+	// The depth of this octree node's cube, zero for the quad-tree node
+	Depth float64
 
 	// The CPPN outputs for this node
 	CppnOut []float64
@@ -78,7 +82,15 @@ func (q *QuadNode) HasLeo() bool {
 	return len(q.CppnOut) > 1
 }
 
+// IsOctant Returns true if this node is the node of octree, i.e., it has a non-zero depth
+func (q *QuadNode) IsOctant() bool {
+	return q.Depth > 0
+}
+
 func (q *QuadNode) String() string {
+	if q.IsOctant() {
+		return fmt.Sprintf("((%f, %f, %f), %f x %f x %f) = %f at %d", q.X, q.Y, q.Z, q.Width, q.Height, q.Depth, q.CppnOut, q.Level)
+	}
 	return fmt.Sprintf("((%f, %f, %f), %f x %f) = %f at %d", q.X, q.Y, q.Z, q.Width, q.Height, q.CppnOut, q.Level)
 }
 
@@ -108,3 +120,19 @@ func NewQuadNodeZ(x, y, z, width, height float64, level int) *QuadNode {
 	}
 	return &node
 }
+
+// NewOctNode Creates a new octree node with given parameters, which represents the cube with given center, width,
+// height, and depth
+func NewOctNode(x, y, z, width, height, depth float64, level int) *QuadNode {
+	node := QuadNode{
+		X:       x,
+		Y:       y,
+		Z:       z,
+		Width:   width,
+		Height:  height,
+		Depth:   depth,
+		CppnOut: []float64{0.0},
+		Level:   level,
+	}
+	return &node
+}
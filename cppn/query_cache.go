@@ -0,0 +1,104 @@
+package cppn
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// QueryCacheStats holds statistics of the CPPN query cache usage
+type QueryCacheStats struct {
+	// Hits The number of CPPN queries answered from the cache
+	Hits int
+	// Misses The number of CPPN queries that was not found in the cache and required CPPN activation
+	Misses int
+	// Evictions The number of cached entries removed to fit the cache capacity
+	Evictions int
+}
+
+// HitRate Returns the ratio of CPPN queries answered from the cache to the total number of queries
+func (s QueryCacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0.0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Returns the sum of this and provided statistics
+func (s QueryCacheStats) add(other QueryCacheStats) QueryCacheStats {
+	return QueryCacheStats{
+		Hits:      s.Hits + other.Hits,
+		Misses:    s.Misses + other.Misses,
+		Evictions: s.Evictions + other.Evictions,
+	}
+}
+
+func (s QueryCacheStats) String() string {
+	return fmt.Sprintf("hits [%d], misses [%d], evictions [%d], hit rate [%.3f]",
+		s.Hits, s.Misses, s.Evictions, s.HitRate())
+}
+
+// The key of the cache entry which holds 6D hypercube coordinates
+type queryCacheKey [6]float64
+
+// The cache entry holding CPPN outputs for specific hypercube point
+type queryCacheEntry struct {
+	key     queryCacheKey
+	outputs []float64
+}
+
+// queryCache The bounded cache of CPPN query results with least recently used entries eviction
+type queryCache struct {
+	// The maximal number of entries to hold
+	capacity int
+	// The map to find entries by the hypercube point
+	entries map[queryCacheKey]*list.Element
+	// The list of entries ordered from the most recently used to the least recently used
+	order *list.List
+	// The cache usage statistics
+	stats QueryCacheStats
+}
+
+// Creates new cache with specified capacity
+func newQueryCache(capacity int) *queryCache {
+	return &queryCache{
+		capacity: capacity,
+		entries:  make(map[queryCacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Returns cached CPPN outputs for the specified hypercube point if found
+func (c *queryCache) get(coordinates []float64) ([]float64, bool) {
+	if elem, ok := c.entries[newQueryCacheKey(coordinates)]; ok {
+		c.stats.Hits++
+		c.order.MoveToFront(elem)
+		return elem.Value.(*queryCacheEntry).outputs, true
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// Stores CPPN outputs for the specified hypercube point, evicting the least recently used entry if the capacity exceeded
+func (c *queryCache) put(coordinates []float64, outputs []float64) {
+	key := newQueryCacheKey(coordinates)
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*queryCacheEntry).outputs = outputs
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.order.Len() >= c.capacity {
+		if last := c.order.Back(); last != nil {
+			c.order.Remove(last)
+			delete(c.entries, last.Value.(*queryCacheEntry).key)
+			c.stats.Evictions++
+		}
+	}
+	c.entries[key] = c.order.PushFront(&queryCacheEntry{key: key, outputs: outputs})
+}
+
+func newQueryCacheKey(coordinates []float64) queryCacheKey {
+	var key queryCacheKey
+	copy(key[:], coordinates)
+	return key
+}
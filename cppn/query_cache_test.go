@@ -0,0 +1,57 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQueryCache_GetPut(t *testing.T) {
+	cache := newQueryCache(2)
+
+	coords := []float64{0.0, 0.0, 0.0, 0.5, 0.5, 0.0}
+	outs, ok := cache.get(coords)
+	assert.False(t, ok)
+	assert.Nil(t, outs)
+
+	cache.put(coords, []float64{0.1, 1.0})
+	outs, ok = cache.get(coords)
+	assert.True(t, ok)
+	assert.Equal(t, []float64{0.1, 1.0}, outs)
+
+	expected := QueryCacheStats{Hits: 1, Misses: 1}
+	assert.Equal(t, expected, cache.stats)
+	assert.Equal(t, 0.5, cache.stats.HitRate())
+}
+
+func TestQueryCache_Eviction(t *testing.T) {
+	cache := newQueryCache(2)
+
+	first := []float64{0.0, 0.0, 0.0, 0.1, 0.1, 0.0}
+	second := []float64{0.0, 0.0, 0.0, 0.2, 0.2, 0.0}
+	third := []float64{0.0, 0.0, 0.0, 0.3, 0.3, 0.0}
+	cache.put(first, []float64{1})
+	cache.put(second, []float64{2})
+
+	// make first recently used
+	_, ok := cache.get(first)
+	assert.True(t, ok)
+
+	// the least recently used second entry should be evicted
+	cache.put(third, []float64{3})
+	assert.Equal(t, 1, cache.stats.Evictions)
+	assert.Len(t, cache.entries, 2)
+
+	_, ok = cache.get(second)
+	assert.False(t, ok)
+	outs, ok := cache.get(first)
+	assert.True(t, ok)
+	assert.Equal(t, []float64{1}, outs)
+	outs, ok = cache.get(third)
+	assert.True(t, ok)
+	assert.Equal(t, []float64{3}, outs)
+}
+
+func TestQueryCacheStats_HitRate_Empty(t *testing.T) {
+	stats := QueryCacheStats{}
+	assert.Equal(t, 0.0, stats.HitRate())
+}
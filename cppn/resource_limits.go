@@ -0,0 +1,130 @@
+package cppn
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"sync"
+)
+
+// ErrResourceLimitExceeded The error to be raised when the resource limit of substrate generation is exceeded. The
+// returned errors are of type *ResourceLimitError wrapping this one.
+var ErrResourceLimitExceeded = errors.New("substrate resource limit exceeded")
+
+// The internal error to stop exploration of the connectivity pattern which exceeded the limit under truncate policy
+var errExplorationTruncated = errors.New("exploration truncated by resource limit")
+
+// ResourceType The type of resource consumed during the evolvable substrate generation
+type ResourceType string
+
+const (
+	// ResourceHiddenNodes The hidden nodes added to the substrate
+	ResourceHiddenNodes ResourceType = "hidden nodes"
+	// ResourceLinks The links expressed in the substrate
+	ResourceLinks ResourceType = "links"
+	// ResourceCppnQueries The CPPN queries made by the quadtree exploration and the encoding of node properties
+	ResourceCppnQueries ResourceType = "CPPN queries"
+	// ResourceQuadTreeDepth The depth of the quadtree built for the explored substrate node
+	ResourceQuadTreeDepth ResourceType = "quadtree depth"
+)
+
+// ResourceLimitError The error returned when the resource limit of substrate generation is exceeded
+type ResourceLimitError struct {
+	// Resource The type of exceeded resource
+	Resource ResourceType
+	// Limit The limit of resource
+	Limit int
+}
+
+func (e *ResourceLimitError) Error() string {
+	return fmt.Sprintf("%s: %s [%d]", ErrResourceLimitExceeded, e.Resource, e.Limit)
+}
+
+func (e *ResourceLimitError) Unwrap() error {
+	return ErrResourceLimitExceeded
+}
+
+// resourceLimits tracks the resources consumed during the evolvable substrate generation against the limits defined
+// by ES-HyperNEAT options. It is safe for concurrent use by exploration workers.
+type resourceLimits struct {
+	// The ES-HyperNEAT options defining limits
+	options *eshyperneat.Options
+
+	// The mutex to guard counters
+	mutex sync.Mutex
+	// The number of CPPN queries made so far
+	cppnQueries int
+	// The resources which limits were exceeded
+	exceeded map[ResourceType]bool
+}
+
+// Creates new resources tracker with limits defined by provided options
+func newResourceLimits(options *eshyperneat.Options) (*resourceLimits, error) {
+	switch options.ResourceLimitPolicy {
+	case "", eshyperneat.ResourceLimitPolicyError, eshyperneat.ResourceLimitPolicyTruncate:
+	default:
+		return nil, errors.Errorf("unsupported resource limit policy: %s", options.ResourceLimitPolicy)
+	}
+	return &resourceLimits{
+		options:  options,
+		exceeded: make(map[ResourceType]bool),
+	}, nil
+}
+
+// Checks whether the resource can be used given that the specified amount is already used and the amount requested.
+// Returns false if the limit exceeded under truncate policy, or the error under error policy.
+func (r *resourceLimits) allow(resource ResourceType, limit, used, requested int) (bool, error) {
+	if limit <= 0 || used+requested <= limit {
+		return true, nil
+	}
+	r.exceeded[resource] = true
+	if r.options.ResourceLimitPolicy == eshyperneat.ResourceLimitPolicyTruncate {
+		return false, nil
+	}
+	return false, &ResourceLimitError{Resource: resource, Limit: limit}
+}
+
+// Checks whether one more hidden node can be added to the substrate having given number of hidden nodes
+func (r *resourceLimits) allowHiddenNode(hiddenCount int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.allow(ResourceHiddenNodes, r.options.MaxHiddenNodes, hiddenCount, 1)
+}
+
+// Checks whether one more link can be added to the substrate having given number of links
+func (r *resourceLimits) allowLink(linksCount int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.allow(ResourceLinks, r.options.MaxLinks, linksCount, 1)
+}
+
+// Checks whether the quadtree can be divided to the given depth
+func (r *resourceLimits) allowDepth(depth int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.allow(ResourceQuadTreeDepth, r.options.MaxQuadTreeDepth, 0, depth)
+}
+
+// Reserves the given number of CPPN queries if it is within the limit
+func (r *resourceLimits) reserveCppnQueries(count int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	ok, err := r.allow(ResourceCppnQueries, r.options.MaxCppnQueries, r.cppnQueries, count)
+	if ok {
+		r.cppnQueries += count
+	}
+	return ok, err
+}
+
+// Returns the resources which limits were exceeded in the order of their declaration
+func (r *resourceLimits) exceededResources() []ResourceType {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	resources := make([]ResourceType, 0, len(r.exceeded))
+	for _, resource := range []ResourceType{ResourceHiddenNodes, ResourceLinks, ResourceCppnQueries, ResourceQuadTreeDepth} {
+		if r.exceeded[resource] {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
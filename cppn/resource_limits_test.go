@@ -0,0 +1,167 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"github.com/yaricom/goNEAT/v4/neat/math"
+	"testing"
+)
+
+func TestResourceLimitError(t *testing.T) {
+	var err error = &ResourceLimitError{Resource: ResourceLinks, Limit: 10}
+	assert.EqualError(t, err, "substrate resource limit exceeded: links [10]")
+	assert.ErrorIs(t, err, ErrResourceLimitExceeded)
+}
+
+func TestResourceLimits(t *testing.T) {
+	// no limits
+	limits, err := newResourceLimits(&eshyperneat.Options{})
+	require.NoError(t, err, "failed to create resource limits")
+	ok, err := limits.allowHiddenNode(1000)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Empty(t, limits.exceededResources())
+
+	// the error policy
+	limits, err = newResourceLimits(&eshyperneat.Options{MaxLinks: 2, MaxCppnQueries: 10})
+	require.NoError(t, err, "failed to create resource limits")
+	ok, err = limits.allowLink(1)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	ok, err = limits.allowLink(2)
+	assert.False(t, ok)
+	var limitErr *ResourceLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, ResourceLinks, limitErr.Resource)
+	assert.Equal(t, 2, limitErr.Limit)
+
+	// the CPPN queries are reserved only within the limit
+	ok, err = limits.reserveCppnQueries(8)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	ok, err = limits.reserveCppnQueries(3)
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, ErrResourceLimitExceeded)
+	ok, err = limits.reserveCppnQueries(2)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, []ResourceType{ResourceLinks, ResourceCppnQueries}, limits.exceededResources())
+
+	// the truncate policy
+	limits, err = newResourceLimits(&eshyperneat.Options{
+		MaxQuadTreeDepth:    4,
+		ResourceLimitPolicy: eshyperneat.ResourceLimitPolicyTruncate,
+	})
+	require.NoError(t, err, "failed to create resource limits")
+	ok, err = limits.allowDepth(5)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, []ResourceType{ResourceQuadTreeDepth}, limits.exceededResources())
+
+	// the unsupported policy
+	_, err = newResourceLimits(&eshyperneat.Options{ResourceLimitPolicy: "unknown"})
+	assert.EqualError(t, err, "unsupported resource limit policy: unknown")
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_ResourceLimits(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+
+	testCases := []struct {
+		name     string
+		limit    func(options *eshyperneat.Options)
+		resource ResourceType
+	}{
+		{name: "hidden nodes", limit: func(o *eshyperneat.Options) { o.MaxHiddenNodes = 2 }, resource: ResourceHiddenNodes},
+		{name: "links", limit: func(o *eshyperneat.Options) { o.MaxLinks = 5 }, resource: ResourceLinks},
+		{name: "CPPN queries", limit: func(o *eshyperneat.Options) { o.MaxCppnQueries = 500 }, resource: ResourceCppnQueries},
+		{name: "quadtree depth", limit: func(o *eshyperneat.Options) { o.MaxQuadTreeDepth = 3 }, resource: ResourceQuadTreeDepth},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+			require.NoError(t, err, "failed to read ESHyperNEAT context")
+			tc.limit(context)
+
+			// the error policy
+			layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+			require.NoError(t, err, "failed to create layout")
+			substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+			solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+			var limitErr *ResourceLimitError
+			require.ErrorAs(t, err, &limitErr)
+			assert.Equal(t, tc.resource, limitErr.Resource)
+			assert.Nil(t, solver)
+			assert.Equal(t, []ResourceType{tc.resource}, substr.ExceededResources())
+
+			// the truncate policy
+			context.ResourceLimitPolicy = eshyperneat.ResourceLimitPolicyTruncate
+			layout, err = NewMappedEvolvableSubstrateLayout(4, 2)
+			require.NoError(t, err, "failed to create layout")
+			substr = NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+			solver, err = substr.CreateNetworkSolver(cppn, nil, context)
+			require.NoError(t, err, "failed to create solver")
+			require.NotNil(t, solver)
+			assert.Equal(t, []ResourceType{tc.resource}, substr.ExceededResources())
+			if context.MaxHiddenNodes > 0 {
+				assert.Equal(t, context.MaxHiddenNodes, layout.HiddenCount())
+			}
+			if context.MaxLinks > 0 {
+				assert.Equal(t, context.MaxLinks, solver.LinkCount())
+			}
+		})
+	}
+
+	// the limits are not exceeded
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.MaxHiddenNodes, context.MaxLinks, context.MaxCppnQueries, context.MaxQuadTreeDepth = 1000, 1000, 1000000, 6
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	assert.Empty(t, substr.ExceededResources())
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_NodeQueriesLimit(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.NodeBiasEnabled = true
+
+	createSolver := func() (*EvolvableSubstrate, *MappedEvolvableSubstrateLayout, error) {
+		layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+		require.NoError(t, err, "failed to create layout")
+		substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+		_, err = substr.CreateNetworkSolver(cppn, nil, context)
+		return substr, layout, err
+	}
+
+	// the number of CPPN queries made by the exploration and by the encoding of output and hidden nodes
+	substr, layout, err := createSolver()
+	require.NoError(t, err, "failed to create solver")
+	queries := substr.QueryCacheStats().Misses + 2 + layout.HiddenCount()
+
+	// the node queries are within the limit
+	context.MaxCppnQueries = queries
+	substr, _, err = createSolver()
+	require.NoError(t, err, "failed to create solver")
+	assert.Empty(t, substr.ExceededResources())
+
+	// the node queries exceed the limit
+	context.MaxCppnQueries = queries - 1
+	substr, _, err = createSolver()
+	var limitErr *ResourceLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, ResourceCppnQueries, limitErr.Resource)
+	assert.Equal(t, []ResourceType{ResourceCppnQueries}, substr.ExceededResources())
+
+	// the exceeding queries are dropped under truncate policy
+	context.ResourceLimitPolicy = eshyperneat.ResourceLimitPolicyTruncate
+	substr, _, err = createSolver()
+	require.NoError(t, err, "failed to create solver")
+	assert.Equal(t, []ResourceType{ResourceCppnQueries}, substr.ExceededResources())
+}
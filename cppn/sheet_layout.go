@@ -0,0 +1,155 @@
+package cppn
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+)
+
+// Sheet Defines the two-dimensional sheet of nodes arranged in rows and columns within the rectangular extent at the
+// given Z plane. The nodes are placed at the centers of the equal cells of the extent in the row-major order, i.e., the
+// node with index i is at row i / Cols and column i % Cols. The rows go along Y axis starting from MinY, and the
+// columns go along X axis starting from MinX. The extent collapsed along any axis places all nodes on its lower bound.
+type Sheet struct {
+	// Rows The number of rows of nodes in the sheet
+	Rows int
+	// Cols The number of columns of nodes in the sheet
+	Cols int
+
+	// MinX The lower bound of the sheet extent along X axis
+	MinX float64
+	// MaxX The upper bound of the sheet extent along X axis
+	MaxX float64
+	// MinY The lower bound of the sheet extent along Y axis
+	MinY float64
+	// MaxY The upper bound of the sheet extent along Y axis
+	MaxY float64
+	// Z The Z plane of the sheet
+	Z float64
+}
+
+// NewSheet Creates new sheet with given number of rows and columns of nodes spread over the extent [-1, 1] x [-1, 1]
+// at the Z plane of zero
+func NewSheet(rows, cols int) Sheet {
+	return Sheet{Rows: rows, Cols: cols, MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: 1.0}
+}
+
+// NewLineSheet Creates new sheet with a single row of given number of nodes spread along X axis within [-1, 1] at
+// the given Y coordinate, as in the GridSubstrateLayout
+func NewLineSheet(count int, y float64) Sheet {
+	return Sheet{Rows: 1, Cols: count, MinX: -1.0, MaxX: 1.0, MinY: y, MaxY: y}
+}
+
+// Count Returns the number of nodes in the sheet
+func (s Sheet) Count() int {
+	return s.Rows * s.Cols
+}
+
+// Position Returns coordinates of the node with specified index [0; count) in the sheet
+func (s Sheet) Position(index int) (*PointF, error) {
+	if index < 0 {
+		return nil, errors.New("neuron index can not be negative")
+	}
+	if index >= s.Count() {
+		return nil, errors.New("neuron index is out of range")
+	}
+	row, col := index/s.Cols, index%s.Cols
+	deltaX := (s.MaxX - s.MinX) / float64(s.Cols)
+	deltaY := (s.MaxY - s.MinY) / float64(s.Rows)
+	// the initial position with a half-delta shift
+	return &PointF{
+		X: s.MinX + deltaX/2.0 + float64(col)*deltaX,
+		Y: s.MinY + deltaY/2.0 + float64(row)*deltaY,
+		Z: s.Z,
+	}, nil
+}
+
+// Validate Checks that dimensions and extent of this sheet are consistent
+func (s Sheet) Validate() error {
+	if s.Rows < 0 || s.Cols < 0 {
+		return errors.Errorf("the number of rows and columns of sheet can not be negative: %d x %d", s.Rows, s.Cols)
+	}
+	if s.MaxX < s.MinX {
+		return errors.Errorf("the upper X bound of sheet can not be less than the lower one: [%f, %f]", s.MinX, s.MaxX)
+	}
+	if s.MaxY < s.MinY {
+		return errors.Errorf("the upper Y bound of sheet can not be less than the lower one: [%f, %f]", s.MinY, s.MaxY)
+	}
+	return nil
+}
+
+func (s Sheet) String() string {
+	return fmt.Sprintf("%d x %d at [%f, %f] x [%f, %f], Z: %f", s.Rows, s.Cols, s.MinX, s.MaxX, s.MinY, s.MaxY, s.Z)
+}
+
+// SheetSubstrateLayout Defines the substrate layout with input, hidden, and output nodes arranged in two-dimensional
+// sheets, e.g., to mirror the pixel geometry of the visual task inputs
+type SheetSubstrateLayout struct {
+	// The number of bias nodes encoded in this substrate
+	biasCount int
+	// The sheet of input nodes
+	inputSheet Sheet
+	// The sheet of hidden nodes
+	hiddenSheet Sheet
+	// The sheet of output nodes
+	outputSheet Sheet
+}
+
+// NewSheetSubstrateLayout Creates new instance with specified number of BIAS nodes and sheets of input, hidden, and
+// output nodes. The BIAS nodes are located at the origin.
+func NewSheetSubstrateLayout(biasCount int, input, hidden, output Sheet) (*SheetSubstrateLayout, error) {
+	if biasCount < 0 {
+		return nil, errors.Errorf("the number of BIAS neurons can not be negative: %d", biasCount)
+	}
+	for _, sheet := range []struct {
+		name  string
+		sheet Sheet
+	}{{"input", input}, {"hidden", hidden}, {"output", output}} {
+		if err := sheet.sheet.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s sheet", sheet.name)
+		}
+	}
+	return &SheetSubstrateLayout{biasCount: biasCount, inputSheet: input, hiddenSheet: hidden, outputSheet: output}, nil
+}
+
+func (l *SheetSubstrateLayout) NodePosition(index int, nType network.NodeNeuronType) (*PointF, error) {
+	if index < 0 {
+		return nil, errors.New("neuron index can not be negative")
+	}
+	switch nType {
+	case network.BiasNeuron:
+		if index < l.biasCount {
+			return &PointF{}, nil // BIAS always located at (0, 0)
+		}
+		return nil, errors.New("the BIAS index is out of range")
+	case network.InputNeuron:
+		return l.inputSheet.Position(index)
+	case network.HiddenNeuron:
+		return l.hiddenSheet.Position(index)
+	case network.OutputNeuron:
+		return l.outputSheet.Position(index)
+	}
+	return nil, errors.Errorf("unsupported neuron type: %s", network.NeuronTypeName(nType))
+}
+
+func (l *SheetSubstrateLayout) BiasCount() int {
+	return l.biasCount
+}
+
+func (l *SheetSubstrateLayout) InputCount() int {
+	return l.inputSheet.Count()
+}
+
+func (l *SheetSubstrateLayout) HiddenCount() int {
+	return l.hiddenSheet.Count()
+}
+
+func (l *SheetSubstrateLayout) OutputCount() int {
+	return l.outputSheet.Count()
+}
+
+func (l *SheetSubstrateLayout) String() string {
+	str := fmt.Sprintf("SheetSubstrateLayout:\n\tINPT: %s\n\tHIDN: %s\n\tOUTP: %s\n\tBIAS: %d",
+		l.inputSheet, l.hiddenSheet, l.outputSheet, l.biasCount)
+	return str
+}
@@ -0,0 +1,114 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goNEAT/v4/neat/network"
+	"testing"
+)
+
+func TestSheet_Position(t *testing.T) {
+	sheet := Sheet{Rows: 2, Cols: 4, MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: 0.0, Z: 0.5}
+	assert.Equal(t, 8, sheet.Count())
+
+	expected := []PointF{
+		{X: -0.75, Y: -0.75, Z: 0.5}, {X: -0.25, Y: -0.75, Z: 0.5}, {X: 0.25, Y: -0.75, Z: 0.5}, {X: 0.75, Y: -0.75, Z: 0.5},
+		{X: -0.75, Y: -0.25, Z: 0.5}, {X: -0.25, Y: -0.25, Z: 0.5}, {X: 0.25, Y: -0.25, Z: 0.5}, {X: 0.75, Y: -0.25, Z: 0.5},
+	}
+	for i, exp := range expected {
+		pos, err := sheet.Position(i)
+		require.NoError(t, err)
+		assert.Equal(t, exp, *pos, "wrong position at: %d", i)
+	}
+
+	pos, err := sheet.Position(8)
+	assert.EqualError(t, err, "neuron index is out of range")
+	assert.Nil(t, pos)
+	pos, err = sheet.Position(-1)
+	assert.EqualError(t, err, "neuron index can not be negative")
+	assert.Nil(t, pos)
+
+	// the empty sheet
+	pos, err = Sheet{}.Position(0)
+	assert.EqualError(t, err, "neuron index is out of range")
+	assert.Nil(t, pos)
+}
+
+func TestNewLineSheet(t *testing.T) {
+	sheet := NewLineSheet(4, -1.0)
+	assert.Equal(t, 4, sheet.Count())
+	grid := NewGridSubstrateLayout(0, 4, 0, 0)
+	for i := 0; i < sheet.Count(); i++ {
+		pos, err := sheet.Position(i)
+		require.NoError(t, err)
+		expected, err := grid.NodePosition(i, network.InputNeuron)
+		require.NoError(t, err)
+		assert.Equal(t, expected, pos, "wrong position at: %d", i)
+	}
+}
+
+func TestSheet_Validate(t *testing.T) {
+	testCases := []struct {
+		sheet Sheet
+		err   string
+	}{
+		{sheet: NewSheet(2, 3)},
+		{sheet: NewLineSheet(3, 1.0)},
+		{sheet: Sheet{Rows: -1, Cols: 2}, err: "the number of rows and columns of sheet can not be negative: -1 x 2"},
+		{sheet: Sheet{Rows: 1, Cols: 2, MinX: 1.0, MaxX: -1.0},
+			err: "the upper X bound of sheet can not be less than the lower one: [1.000000, -1.000000]"},
+		{sheet: Sheet{Rows: 1, Cols: 2, MinY: 1.0, MaxY: 0.0},
+			err: "the upper Y bound of sheet can not be less than the lower one: [1.000000, 0.000000]"},
+	}
+	for _, tc := range testCases {
+		err := tc.sheet.Validate()
+		if tc.err == "" {
+			assert.NoError(t, err, tc.sheet.String())
+		} else {
+			assert.EqualError(t, err, tc.err)
+		}
+	}
+}
+
+func TestSheetSubstrateLayout_NodePosition(t *testing.T) {
+	input := Sheet{Rows: 2, Cols: 2, MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: -0.5}
+	hidden := NewSheet(1, 2)
+	output := Sheet{Rows: 1, Cols: 1, MinX: -0.5, MaxX: 0.5, MinY: 1.0, MaxY: 1.0, Z: 1.0}
+	layout, err := NewSheetSubstrateLayout(1, input, hidden, output)
+	require.NoError(t, err)
+	assert.Equal(t, 1, layout.BiasCount())
+	assert.Equal(t, 4, layout.InputCount())
+	assert.Equal(t, 2, layout.HiddenCount())
+	assert.Equal(t, 1, layout.OutputCount())
+
+	testCases := []struct {
+		nType    network.NodeNeuronType
+		expected []PointF
+	}{
+		{nType: network.BiasNeuron, expected: []PointF{{}}},
+		{nType: network.InputNeuron, expected: []PointF{
+			{X: -0.5, Y: -0.875}, {X: 0.5, Y: -0.875}, {X: -0.5, Y: -0.625}, {X: 0.5, Y: -0.625}}},
+		{nType: network.HiddenNeuron, expected: []PointF{{X: -0.5, Y: 0.0}, {X: 0.5, Y: 0.0}}},
+		{nType: network.OutputNeuron, expected: []PointF{{X: 0.0, Y: 1.0, Z: 1.0}}},
+	}
+	for _, tc := range testCases {
+		for i, expected := range tc.expected {
+			pos, err := layout.NodePosition(i, tc.nType)
+			require.NoError(t, err)
+			assert.Equal(t, expected, *pos, "wrong position of %s node at: %d", network.NeuronTypeName(tc.nType), i)
+		}
+		pos, err := layout.NodePosition(len(tc.expected), tc.nType)
+		assert.Error(t, err)
+		assert.Nil(t, pos)
+	}
+}
+
+func TestNewSheetSubstrateLayout_Errors(t *testing.T) {
+	layout, err := NewSheetSubstrateLayout(-1, NewSheet(1, 1), NewSheet(1, 1), NewSheet(1, 1))
+	assert.EqualError(t, err, "the number of BIAS neurons can not be negative: -1")
+	assert.Nil(t, layout)
+
+	layout, err = NewSheetSubstrateLayout(1, NewSheet(1, 1), Sheet{Rows: 1, Cols: -1}, NewSheet(1, 1))
+	assert.EqualError(t, err, "invalid hidden sheet: the number of rows and columns of sheet can not be negative: 1 x -1")
+	assert.Nil(t, layout)
+}
@@ -1,12 +1,12 @@
 package cppn
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
 	neatmath "github.com/yaricom/goNEAT/v4/neat/math"
 	"github.com/yaricom/goNEAT/v4/neat/network"
-	"math"
 )
 
 // Substrate represents a substrate that holds configuration of ANN with weights produced by CPPN.
@@ -20,6 +20,17 @@ type Substrate struct {
 	HiddenNodesActivation neatmath.NodeActivationType
 	// OutputNodesActivation The activation function type for output neurons encoded
 	OutputNodesActivation neatmath.NodeActivationType
+
+	// Encoder The encoder of the hypercube coordinates into the CPPN inputs
+	Encoder CoordinateEncoder
+	// LinkExpression The strategy to decide whether to express a link between substrate nodes. If not set, the
+	// strategy defined by HyperNEAT options is used.
+	LinkExpression LinkExpressionStrategy
+	// Connections The plan of connections between named layers of the substrate layout, e.g., input→h1, h1→h2,
+	// h2→output, and input→h2 as a skip connection. The layouts without named layers have the layers named input,
+	// hidden, and output. If not set, the layers are connected sequentially in order input→hidden→output as in
+	// LayeredSubstrateLayout.SequentialConnections, or input→output if there are no hidden nodes.
+	Connections []LayerConnection
 }
 
 // NewSubstrate creates a new instance of substrate.
@@ -28,26 +39,71 @@ func NewSubstrate(layout SubstrateLayout, hiddenNodesActivation, outputNodesActi
 		Layout:                layout,
 		HiddenNodesActivation: hiddenNodesActivation,
 		OutputNodesActivation: outputNodesActivation,
+		Encoder:               NewCoordinateEncoder(false),
 	}
 	return &substr
 }
 
 // CreateNetworkSolver creates a network solver based on the current substrate layout and provided
 // Compositional Pattern Producing Network, which used to define connections between network nodes.
+// The CPPN is queried for the links between all nodes of the layers connected by the substrate connection plan.
 // Optional graph_builder can be provided to collect graph nodes and edges
 // of the created network solver. With graph builder it is possible to save/load network configuration as well as visualize it.
 // If the useLeo is True, thar Link Expression Output extension to the HyperNEAT will be used instead of the standard weight threshold
 // technique of HyperNEAT to determine whether to express a link between two nodes or not. With LEO the link is expressed based
-// on the value of additional output of the CPPN (if > 0 then expressed)
+// on the value of additional output of the CPPN (if > 0 then expressed). If the type of link expression strategy is
+// defined by options or the substrate has its own strategy, it overrides the useLeo flag.
+// If node biases are enabled by options, the bias of each hidden and output node will be queried from CPPN at the node
+// position. Note that biases are applied by the created solver only during forward activation steps. Similarly, if the
+// palette of node activators is defined by options, the activation function of each hidden and output node will be
+// selected by CPPN. The activation functions defined by the layout implementing NodeActivationLayout take precedence
+// over both. If plasticity is enabled by options, the Hebbian learning rule of each link is read from the CPPN
+// outputs queried for that link and the PlasticNetworkSolver is created, which updates link weights online during
+// activation. The bias links are not plastic. If neuromodulation is enabled as well, the CPPN decides which hidden
+// nodes are modulatory.
 func (s *Substrate) CreateNetworkSolver(cppn network.Solver, useLeo bool, graphBuilder SubstrateGraphBuilder, options *hyperneat.Options) (network.Solver, error) {
+	return s.CreateNetworkSolverContext(context.Background(), cppn, useLeo, graphBuilder, options)
+}
+
+// CreateNetworkSolverContext Creates a network solver the same way as CreateNetworkSolver, but checks the provided
+// context for cancellation and deadline between CPPN queries. If the context is cancelled or its deadline exceeded,
+// the creation is interrupted and the context error is returned.
+func (s *Substrate) CreateNetworkSolverContext(ctx context.Context, cppn network.Solver, useLeo bool, graphBuilder SubstrateGraphBuilder, options *hyperneat.Options) (network.Solver, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// check conditions
 	if s.Layout.BiasCount() > 1 {
 		return nil, errors.New("SUBSTRATE: maximum one BIAS node per network supported")
 	}
+	if err := validatePlasticityOptions(options); err != nil {
+		return nil, err
+	}
+	if net, ok := cppn.(*network.Network); ok {
+		if err := validateNetworkEncoder(s.Encoder, net); err != nil {
+			return nil, err
+		}
+	}
+	layers, connections, err := resolveConnectionPlan(s.Layout, s.Connections)
+	if err != nil {
+		return nil, err
+	}
+
+	// the strategy to decide whether to express links between nodes
+	expression, err := s.linkExpression(useLeo, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the implicit BIAS neuron is added to hold CPPN encoded node biases if layout has no BIAS nodes
+	biasCount := s.Layout.BiasCount()
+	if biasCount == 0 && options.NodeBiasEnabled {
+		biasCount = 1
+	}
 
 	// the network layers will be collected in order: bias, input, output, hidden
 	firstBias := 0
-	firstInput := s.Layout.BiasCount()
+	firstInput := biasCount
 	firstOutput := firstInput + s.Layout.InputCount()
 	firstHidden := firstOutput + s.Layout.OutputCount()
 	lastHidden := firstHidden + s.Layout.HiddenCount()
@@ -56,6 +112,34 @@ func (s *Substrate) CreateNetworkSolver(cppn network.Solver, useLeo bool, graphB
 
 	links := make([]*network.FastNetworkLink, 0)
 	biasList := make([]float64, totalNeuronCount)
+	// the learning rules of plastic links if appropriate
+	rules := make([]HebbianRule, 0)
+
+	// query CPPN for properties of the output and hidden nodes if appropriate
+	nodes := newNodeEncoder(cppn, s.Encoder, options, nil)
+	if nodes.enabled() {
+		indexes := make([]int, 0, lastHidden-firstOutput)
+		positions := make([]*PointF, 0, lastHidden-firstOutput)
+		for oi := firstOutput; oi < firstHidden; oi++ {
+			if outputPosition, err := s.Layout.NodePosition(oi-firstOutput, network.OutputNeuron); err != nil {
+				return nil, err
+			} else {
+				indexes = append(indexes, oi)
+				positions = append(positions, outputPosition)
+			}
+		}
+		for hi := firstHidden; hi < lastHidden; hi++ {
+			if hiddenPosition, err := s.Layout.NodePosition(hi-firstHidden, network.HiddenNeuron); err != nil {
+				return nil, err
+			} else {
+				indexes = append(indexes, hi)
+				positions = append(positions, hiddenPosition)
+			}
+		}
+		if err := nodes.query(ctx, indexes, positions); err != nil {
+			return nil, err
+		}
+	}
 
 	// inline function to find an activation type for a given neuron
 	activationForNeuron := func(nodeIndex int) neatmath.NodeActivationType {
@@ -64,29 +148,57 @@ func (s *Substrate) CreateNetworkSolver(cppn network.Solver, useLeo bool, graphB
 			return neatmath.LinearActivation
 		} else if nodeIndex < firstHidden {
 			// output nodes activations
-			return s.OutputNodesActivation
+			return layoutActivation(s.Layout, nodeIndex-firstOutput, network.OutputNeuron,
+				nodes.activation(nodeIndex, s.OutputNodesActivation))
 		} else {
 			// hidden nodes activation
-			return s.HiddenNodesActivation
+			return layoutActivation(s.Layout, nodeIndex-firstHidden, network.HiddenNeuron,
+				nodes.activation(nodeIndex, s.HiddenNodesActivation))
 		}
 	}
 
-	// give bias inputs to all hidden and output nodes.
-	coordinates := make([]float64, 6)
-	for bi := firstBias; bi < firstInput; bi++ {
+	// inline function to find a function type for a given neuron, only hidden nodes can be modulatory
+	functionForNeuron := func(nodeIndex int) NodeFunctionType {
+		if nodeIndex < firstHidden {
+			return NodeFunctionStandard
+		}
+		return nodes.function(nodeIndex)
+	}
 
-		// the bias coordinates
-		if biasPosition, err := s.Layout.NodePosition(bi-firstBias, network.BiasNeuron); err != nil {
+	// the hypercube coordinates of all potential links to be queried with CPPN in one batch
+	queries := make([]linkQuery, 0)
+	coordinatesBatch := make([][]float64, 0)
+	queueLink := func(sourcePosition, targetPosition *PointF, source, target int) {
+		queries = append(queries, linkQuery{source: source, target: target, sourcePosition: sourcePosition, targetPosition: targetPosition})
+		coordinatesBatch = append(coordinatesBatch, encodeCoordinates(s.Encoder, *sourcePosition, *targetPosition))
+	}
+
+	// add hidden and output nodes to the graph
+	for hi := firstHidden; hi < lastHidden; hi++ {
+		if hiddenPosition, err := s.Layout.NodePosition(hi-firstHidden, network.HiddenNeuron); err != nil {
 			return nil, err
-		} else {
-			coordinates[0] = biasPosition.X
-			coordinates[1] = biasPosition.Y
-			coordinates[2] = biasPosition.Z
+		} else if _, err = addNodeToBuilder(graphBuilder, hi, network.HiddenNeuron, functionForNeuron(hi), activationForNeuron(hi), hiddenPosition); err != nil {
+			return nil, err
+		}
+	}
+	for oi := firstOutput; oi < firstHidden; oi++ {
+		if outputPosition, err := s.Layout.NodePosition(oi-firstOutput, network.OutputNeuron); err != nil {
+			return nil, err
+		} else if _, err = addNodeToBuilder(graphBuilder, oi, network.OutputNeuron, functionForNeuron(oi), activationForNeuron(oi), outputPosition); err != nil {
+			return nil, err
+		}
+	}
 
-			// add bias node to builder
-			if _, err = addNodeToBuilder(graphBuilder, bi, network.BiasNeuron, activationForNeuron(bi), biasPosition); err != nil {
-				return nil, err
-			}
+	// give bias inputs to all hidden and output nodes.
+	for bi := firstBias; bi < firstBias+s.Layout.BiasCount(); bi++ {
+		// the bias coordinates
+		biasPosition, err := s.Layout.NodePosition(bi-firstBias, network.BiasNeuron)
+		if err != nil {
+			return nil, err
+		}
+		// add bias node to builder
+		if _, err = addNodeToBuilder(graphBuilder, bi, network.BiasNeuron, functionForNeuron(bi), activationForNeuron(bi), biasPosition); err != nil {
+			return nil, err
 		}
 
 		// link the bias to all hidden nodes.
@@ -95,24 +207,7 @@ func (s *Substrate) CreateNetworkSolver(cppn network.Solver, useLeo bool, graphB
 			if hiddenPosition, err := s.Layout.NodePosition(hi-firstHidden, network.HiddenNeuron); err != nil {
 				return nil, err
 			} else {
-				coordinates[2] = hiddenPosition.X
-				coordinates[3] = hiddenPosition.Y
-				coordinates[4] = hiddenPosition.Z
-
-				// add node to the graph
-				if _, err = addNodeToBuilder(graphBuilder, hi, network.HiddenNeuron, activationForNeuron(hi), hiddenPosition); err != nil {
-					return nil, err
-				}
-			}
-			// find connection weight
-			if link, err := fastNetworkLink(coordinates, cppn, useLeo, bi, hi, options); err != nil {
-				return nil, err
-			} else if link != nil {
-				biasList[hi] = link.Weight
-				// add node and edge to the graph
-				if _, err := addEdgeToBuilder(graphBuilder, bi, hi, link.Weight); err != nil {
-					return nil, err
-				}
+				queueLink(biasPosition, hiddenPosition, bi, hi)
 			}
 		}
 
@@ -122,133 +217,76 @@ func (s *Substrate) CreateNetworkSolver(cppn network.Solver, useLeo bool, graphB
 			if outputPosition, err := s.Layout.NodePosition(oi-firstOutput, network.OutputNeuron); err != nil {
 				return nil, err
 			} else {
-				coordinates[2] = outputPosition.X
-				coordinates[3] = outputPosition.Y
-				coordinates[4] = outputPosition.Z
-
-				// add node to the graph
-				if _, err = addNodeToBuilder(graphBuilder, oi, network.OutputNeuron, activationForNeuron(oi), outputPosition); err != nil {
-					return nil, err
-				}
-			}
-			// find connection weight
-			if link, err := fastNetworkLink(coordinates, cppn, useLeo, bi, oi, options); err != nil {
-				return nil, err
-			} else if link != nil {
-				biasList[oi] = link.Weight
-				// add node and edge to the graph
-				if _, err := addEdgeToBuilder(graphBuilder, bi, oi, link.Weight); err != nil {
-					return nil, err
-				}
+				queueLink(biasPosition, outputPosition, bi, oi)
 			}
 		}
 	}
 
-	if s.Layout.HiddenCount() > 0 {
-		// link input nodes to hidden ones
-		for in := firstInput; in < firstOutput; in++ {
-			// get coordinates of input node
-			if inputPosition, err := s.Layout.NodePosition(in-firstInput, network.InputNeuron); err != nil {
-				return nil, err
-			} else {
-				coordinates[0] = inputPosition.X
-				coordinates[1] = inputPosition.Y
-				coordinates[2] = inputPosition.Z
-
-				// add node to the graph
-				if _, err = addNodeToBuilder(graphBuilder, in, network.InputNeuron, activationForNeuron(in), inputPosition); err != nil {
-					return nil, err
-				}
-			}
-			for hi := firstHidden; hi < lastHidden; hi++ {
-				// get hidden neuron coordinates
-				if hiddenPosition, err := s.Layout.NodePosition(hi-firstHidden, network.HiddenNeuron); err != nil {
-					return nil, err
-				} else {
-					coordinates[2] = hiddenPosition.X
-					coordinates[3] = hiddenPosition.Y
-					coordinates[4] = hiddenPosition.Z
-				}
-				// find connection weight
-				if link, err := fastNetworkLink(coordinates, cppn, useLeo, in, hi, options); err != nil {
-					return nil, err
-				} else if link != nil {
-					links = append(links, link)
-					// add node and edge to the graph
-					if _, err := addEdgeToBuilder(graphBuilder, in, hi, link.Weight); err != nil {
-						return nil, err
-					}
-				}
-			}
+	// add input nodes to the graph
+	for in := firstInput; in < firstOutput; in++ {
+		if inputPosition, err := s.Layout.NodePosition(in-firstInput, network.InputNeuron); err != nil {
+			return nil, err
+		} else if _, err = addNodeToBuilder(graphBuilder, in, network.InputNeuron, functionForNeuron(in), activationForNeuron(in), inputPosition); err != nil {
+			return nil, err
 		}
+	}
 
-		// link all hidden nodes to all output nodes.
-		for hi := firstHidden; hi < lastHidden; hi++ {
-			if hiddenPosition, err := s.Layout.NodePosition(hi-firstHidden, network.HiddenNeuron); err != nil {
+	// link all nodes of the source layer to all nodes of the target layer for each planned connection
+	firstOfType := map[network.NodeNeuronType]int{
+		network.InputNeuron:  firstInput,
+		network.OutputNeuron: firstOutput,
+		network.HiddenNeuron: firstHidden,
+	}
+	for _, connection := range connections {
+		source, target := layers[connection.Source], layers[connection.Target]
+		for si := source.offset; si < source.offset+source.count; si++ {
+			sourcePosition, err := s.Layout.NodePosition(si, source.nType)
+			if err != nil {
 				return nil, err
-			} else {
-				coordinates[0] = hiddenPosition.X
-				coordinates[1] = hiddenPosition.Y
-				coordinates[2] = hiddenPosition.Z
 			}
-			for oi := firstOutput; oi < firstHidden; oi++ {
-				// get output neuron coordinates
-				if outputPosition, err := s.Layout.NodePosition(oi-firstOutput, network.OutputNeuron); err != nil {
+			for ti := target.offset; ti < target.offset+target.count; ti++ {
+				// get target neuron coordinates
+				if targetPosition, err := s.Layout.NodePosition(ti, target.nType); err != nil {
 					return nil, err
 				} else {
-					coordinates[2] = outputPosition.X
-					coordinates[3] = outputPosition.Y
-					coordinates[4] = outputPosition.Z
-				}
-				// find connection weight
-				if link, err := fastNetworkLink(coordinates, cppn, useLeo, hi, oi, options); err != nil {
-					return nil, err
-				} else if link != nil {
-					links = append(links, link)
-					// add node and edge to the graph
-					if _, err := addEdgeToBuilder(graphBuilder, hi, oi, link.Weight); err != nil {
-						return nil, err
-					}
+					queueLink(sourcePosition, targetPosition, firstOfType[source.nType]+si, firstOfType[target.nType]+ti)
 				}
 			}
 		}
-	} else {
-		// connect all input nodes directly to all output nodes
-		for in := firstInput; in < firstOutput; in++ {
-			// get coordinates of input node
-			if inputPosition, err := s.Layout.NodePosition(in-firstInput, network.InputNeuron); err != nil {
-				return nil, err
-			} else {
-				coordinates[0] = inputPosition.X
-				coordinates[1] = inputPosition.Y
-				coordinates[2] = inputPosition.Z
+	}
 
-				// add node to the graph
-				if _, err = addNodeToBuilder(graphBuilder, in, network.InputNeuron, activationForNeuron(in), inputPosition); err != nil {
-					return nil, err
-				}
-			}
-			for oi := firstOutput; oi < firstHidden; oi++ {
-				// get output neuron coordinates
-				if outputPosition, err := s.Layout.NodePosition(oi-firstOutput, network.OutputNeuron); err != nil {
-					return nil, err
-				} else {
-					coordinates[2] = outputPosition.X
-					coordinates[3] = outputPosition.Y
-					coordinates[4] = outputPosition.Z
-				}
-				// find connection weight
-				if link, err := fastNetworkLink(coordinates, cppn, useLeo, in, oi, options); err != nil {
+	// query CPPN for all potential links at once and express links where appropriate
+	outputs, err := QueryCPPNBatchContext(ctx, coordinatesBatch, cppn)
+	if err != nil {
+		return nil, err
+	}
+	for i, query := range queries {
+		weight, ok := expression.ExpressLink(outputs[i][0], cppnLeo(outputs[i]), *query.sourcePosition, *query.targetPosition)
+		if !ok {
+			continue
+		}
+		if query.source < firstInput {
+			// the bias links are stored as biases of the target nodes
+			biasList[query.target] = weight
+		} else {
+			links = append(links, createLink(weight, query.source, query.target))
+			if options.PlasticityEnabled {
+				rule, err := newHebbianRule(outputs[i], options)
+				if err != nil {
 					return nil, err
-				} else if link != nil {
-					links = append(links, link)
-					// add node and edge to the graph
-					if _, err := addEdgeToBuilder(graphBuilder, in, oi, link.Weight); err != nil {
-						return nil, err
-					}
 				}
+				rules = append(rules, rule)
 			}
 		}
+		// add edge to the graph
+		if _, err = addEdgeToBuilder(graphBuilder, query.source, query.target, weight); err != nil {
+			return nil, err
+		}
+	}
+
+	// set biases of the hidden and output nodes encoded by CPPN
+	for i := firstOutput; i < lastHidden; i++ {
+		biasList[i] += nodes.bias(i)
 	}
 
 	// build activations
@@ -263,22 +301,41 @@ func (s *Substrate) CreateNetworkSolver(cppn network.Solver, useLeo bool, graphB
 		return nil, errors.New(message)
 	}
 
+	if options.PlasticityEnabled {
+		// create a plastic network solver
+		var functions []NodeFunctionType
+		if options.NeuromodulationEnabled {
+			functions = make([]NodeFunctionType, totalNeuronCount)
+			for i := 0; i < totalNeuronCount; i++ {
+				functions[i] = functionForNeuron(i)
+			}
+		}
+		solver, err := NewModulatedPlasticNetworkSolver(
+			biasCount, s.Layout.InputCount(), s.Layout.OutputCount(), totalNeuronCount,
+			activations, functions, links, rules, biasList, options.WeightRange)
+		if err != nil {
+			return nil, err
+		}
+		return solver, nil
+	}
+
 	// create a fast network solver
 	solver := network.NewFastModularNetworkSolver(
-		s.Layout.BiasCount(), s.Layout.InputCount(), s.Layout.OutputCount(), totalNeuronCount,
+		biasCount, s.Layout.InputCount(), s.Layout.OutputCount(), totalNeuronCount,
 		activations, links, biasList, nil)
 	return solver, nil
 }
 
-func fastNetworkLink(coordinates []float64, cppn network.Solver, useLeo bool, source, target int, options *hyperneat.Options) (*network.FastNetworkLink, error) {
-	if outs, err := queryCPPN(coordinates, cppn); err != nil {
-		return nil, err
-	} else if useLeo && outs[1] > 0 {
-		// add links only when CPPN LEO output signals to
-		return createLink(outs[0], source, target, options.WeightRange), nil
-	} else if !useLeo && math.Abs(outs[0]) >= options.LinkThreshold {
-		// add only links with signal exceeding a provided threshold
-		return createThresholdNormalizedLink(outs[0], source, target, options.LinkThreshold, options.WeightRange), nil
+// Returns the link expression strategy of this substrate or creates the one defined by provided options
+func (s *Substrate) linkExpression(useLeo bool, options *hyperneat.Options) (LinkExpressionStrategy, error) {
+	if s.LinkExpression != nil {
+		return s.LinkExpression, nil
 	}
-	return nil, nil
+	return newLinkExpressionStrategy(options, useLeo)
+}
+
+// linkQuery holds the source and target neurons' indices and positions of the potential link to be queried with CPPN
+type linkQuery struct {
+	source, target                 int
+	sourcePosition, targetPosition *PointF
 }
@@ -11,9 +11,11 @@ import (
 const (
 	nodeAttrID                 = "id"
 	nodeAttrNodeNeuronType     = "NodeNeuronType"
+	nodeAttrNodeFunctionType   = "NodeFunctionType"
 	nodeAttrNodeActivationType = "NodeActivationType"
 	nodeAttrX                  = "X"
 	nodeAttrY                  = "Y"
+	nodeAttrZ                  = "Z"
 	edgeAttrWeight             = "weight"
 	edgeAttrSourceId           = "sourceId"
 	edgeAttrTargetId           = "targetId"
@@ -37,6 +39,13 @@ type SubstrateGraphBuilder interface {
 	UnMarshal(r io.Reader) error
 }
 
+// NodeFunctionGraphBuilder The optional capability of the SubstrateGraphBuilder to record the functions of nodes. The
+// substrates set the function of each node added to the graph if their graph builder implements this interface.
+type NodeFunctionGraphBuilder interface {
+	// SetNodeFunction Sets the function of the node already added to the graph
+	SetNodeFunction(nodeId int, nodeFunction NodeFunctionType) error
+}
+
 // The graph builder based on GraphML specification
 type graphMLBuilder struct {
 	// The GraphML instance
@@ -70,6 +79,7 @@ func (b *graphMLBuilder) AddNode(nodeId int, nodeNeuronType network.NodeNeuronTy
 	}
 	nodeAttr[nodeAttrX] = position.X
 	nodeAttr[nodeAttrY] = position.Y
+	nodeAttr[nodeAttrZ] = position.Z
 
 	// add node to the graph
 	if graph, err := b.graph(); err != nil {
@@ -83,6 +93,14 @@ func (b *graphMLBuilder) AddNode(nodeId int, nodeNeuronType network.NodeNeuronTy
 	return nil
 }
 
+func (b *graphMLBuilder) SetNodeFunction(nodeId int, nodeFunction NodeFunctionType) error {
+	if node, ok := b.nodesMap[nodeId]; !ok {
+		return errors.New("node not found")
+	} else {
+		return node.SetAttribute(nodeAttrNodeFunctionType, string(nodeFunction))
+	}
+}
+
 func (b *graphMLBuilder) AddWeightedEdge(sourceId, targetId int, weight float64) error {
 	// create attribute map
 	edgeAttr := make(map[string]interface{})
@@ -151,12 +169,17 @@ func (b *graphMLBuilder) graph() (*graphml.Graph, error) {
 }
 
 func addNodeToBuilder(builder SubstrateGraphBuilder, nodeId int, nodeType network.NodeNeuronType,
-	nodeActivation math.NodeActivationType, position *PointF) (bool, error) {
+	nodeFunction NodeFunctionType, nodeActivation math.NodeActivationType, position *PointF) (bool, error) {
 	if builder == nil {
 		return false, nil
 	} else if err := builder.AddNode(nodeId, nodeType, nodeActivation, position); err != nil {
 		return false, err
 	}
+	if functionBuilder, ok := builder.(NodeFunctionGraphBuilder); ok {
+		if err := functionBuilder.SetNodeFunction(nodeId, nodeFunction); err != nil {
+			return false, err
+		}
+	}
 	return true, nil
 }
 
@@ -28,7 +28,7 @@ func TestGraphMLBuilder_AddNode(t *testing.T) {
 	// add test nodes
 	nodes := createTestNodes()
 	for _, node := range nodes {
-		position := &PointF{X: node[nodeAttrX].(float64), Y: node[nodeAttrY].(float64)}
+		position := &PointF{X: node[nodeAttrX].(float64), Y: node[nodeAttrY].(float64), Z: node[nodeAttrZ].(float64)}
 		err := builder.AddNode(
 			node[nodeAttrID].(int),
 			node[nodeAttrNodeNeuronType].(network.NodeNeuronType),
@@ -62,6 +62,40 @@ func TestGraphMLBuilder_AddNode(t *testing.T) {
 	}
 }
 
+func TestGraphMLBuilder_SetNodeFunction(t *testing.T) {
+	builder := NewSubstrateGraphMLBuilder("test set node function graph", false)
+	functionBuilder, ok := builder.(NodeFunctionGraphBuilder)
+	require.True(t, ok, "graph builder should record node functions")
+
+	// add nodes with functions
+	for _, node := range createTestNodes() {
+		position := &PointF{X: node[nodeAttrX].(float64), Y: node[nodeAttrY].(float64), Z: node[nodeAttrZ].(float64)}
+		nodeId := node[nodeAttrID].(int)
+		_, err := addNodeToBuilder(builder, nodeId, node[nodeAttrNodeNeuronType].(network.NodeNeuronType),
+			NodeFunctionStandard, node[nodeAttrNodeActivationType].(math.NodeActivationType), position)
+		require.NoError(t, err, "failed to add node")
+	}
+	err := functionBuilder.SetNodeFunction(4, NodeFunctionModulatory)
+	require.NoError(t, err, "failed to set node function")
+
+	// test results
+	graph, err := builder.(*graphMLBuilder).graph()
+	require.NoError(t, err, "failed to build graph")
+	for _, gNode := range graph.Nodes {
+		gnAttr, err := gNode.GetAttributes()
+		require.NoError(t, err, "failed to get node attributes")
+		expected := NodeFunctionStandard
+		if gnAttr[nodeAttrID] == 4 {
+			expected = NodeFunctionModulatory
+		}
+		assert.Equal(t, string(expected), gnAttr[nodeAttrNodeFunctionType], "wrong function type of node: %v", gNode)
+	}
+
+	// the function of unknown node can not be set
+	err = functionBuilder.SetNodeFunction(10, NodeFunctionModulatory)
+	assert.EqualError(t, err, "node not found")
+}
+
 func TestGraphMLBuilder_AddWeightedEdge(t *testing.T) {
 	description := "test add edge graph"
 	builder := NewSubstrateGraphMLBuilder(description, false).(*graphMLBuilder)
@@ -69,7 +103,7 @@ func TestGraphMLBuilder_AddWeightedEdge(t *testing.T) {
 	// add nodes
 	nodes := createTestNodes()
 	for _, node := range nodes {
-		position := &PointF{X: node[nodeAttrX].(float64), Y: node[nodeAttrY].(float64)}
+		position := &PointF{X: node[nodeAttrX].(float64), Y: node[nodeAttrY].(float64), Z: node[nodeAttrZ].(float64)}
 		err := builder.AddNode(
 			node[nodeAttrID].(int),
 			node[nodeAttrNodeNeuronType].(network.NodeNeuronType),
@@ -106,7 +140,7 @@ func TestGraphMLBuilder_Marshal(t *testing.T) {
 	// add nodes
 	nodes := createTestNodes()
 	for _, node := range nodes {
-		position := &PointF{X: node[nodeAttrX].(float64), Y: node[nodeAttrY].(float64)}
+		position := &PointF{X: node[nodeAttrX].(float64), Y: node[nodeAttrY].(float64), Z: node[nodeAttrZ].(float64)}
 		err := builder.AddNode(
 			node[nodeAttrID].(int),
 			node[nodeAttrNodeNeuronType].(network.NodeNeuronType),
@@ -155,12 +189,12 @@ func createTestEdges() []map[string]interface{} {
 
 func createTestNodes() []map[string]interface{} {
 	return []map[string]interface{}{
-		{"id": 1, "X": -0.5, "Y": -1.0, "NodeNeuronType": network.InputNeuron, "NodeActivationType": math.NullActivation},
-		{"id": 2, "X": 0.5, "Y": -1.0, "NodeNeuronType": network.InputNeuron, "NodeActivationType": math.NullActivation},
-		{"id": 3, "X": 0.0, "Y": 0.0, "NodeNeuronType": network.HiddenNeuron, "NodeActivationType": math.SigmoidSteepenedActivation},
-		{"id": 4, "X": 0.0, "Y": 0.0, "NodeNeuronType": network.HiddenNeuron, "NodeActivationType": math.SigmoidSteepenedActivation},
-		{"id": 5, "X": 0.0, "Y": 1.0, "NodeNeuronType": network.OutputNeuron, "NodeActivationType": math.LinearActivation},
+		{"id": 1, "X": -0.5, "Y": -1.0, "Z": -0.5, "NodeNeuronType": network.InputNeuron, "NodeActivationType": math.NullActivation},
+		{"id": 2, "X": 0.5, "Y": -1.0, "Z": -0.5, "NodeNeuronType": network.InputNeuron, "NodeActivationType": math.NullActivation},
+		{"id": 3, "X": 0.0, "Y": 0.0, "Z": 0.0, "NodeNeuronType": network.HiddenNeuron, "NodeActivationType": math.SigmoidSteepenedActivation},
+		{"id": 4, "X": 0.0, "Y": 0.0, "Z": 0.0, "NodeNeuronType": network.HiddenNeuron, "NodeActivationType": math.SigmoidSteepenedActivation},
+		{"id": 5, "X": 0.0, "Y": 1.0, "Z": 0.5, "NodeNeuronType": network.OutputNeuron, "NodeActivationType": math.LinearActivation},
 	}
 }
 
-const graphXml = "<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:schemaLocation=\"http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd\"><desc>test marshal graph</desc><key id=\"d0\" for=\"node\" attr.name=\"id\" attr.type=\"int\"></key><key id=\"d1\" for=\"node\" attr.name=\"NodeNeuronType\" attr.type=\"string\"></key><key id=\"d2\" for=\"node\" attr.name=\"NodeActivationType\" attr.type=\"string\"></key><key id=\"d3\" for=\"node\" attr.name=\"X\" attr.type=\"double\"></key><key id=\"d4\" for=\"node\" attr.name=\"Y\" attr.type=\"double\"></key><key id=\"d5\" for=\"edge\" attr.name=\"weight\" attr.type=\"double\"></key><key id=\"d6\" for=\"edge\" attr.name=\"sourceId\" attr.type=\"int\"></key><key id=\"d7\" for=\"edge\" attr.name=\"targetId\" attr.type=\"int\"></key><graph id=\"g0\" edgedefault=\"directed\"><node id=\"n0\"><data key=\"d0\">1</data><data key=\"d1\">INPT</data><data key=\"d2\">NullActivation</data><data key=\"d3\">-0.5</data><data key=\"d4\">-1</data></node><node id=\"n1\"><data key=\"d0\">2</data><data key=\"d1\">INPT</data><data key=\"d2\">NullActivation</data><data key=\"d3\">0.5</data><data key=\"d4\">-1</data></node><node id=\"n2\"><data key=\"d2\">SigmoidSteepenedActivation</data><data key=\"d3\">0</data><data key=\"d4\">0</data><data key=\"d0\">3</data><data key=\"d1\">HIDN</data></node><node id=\"n3\"><data key=\"d1\">HIDN</data><data key=\"d2\">SigmoidSteepenedActivation</data><data key=\"d3\">0</data><data key=\"d4\">0</data><data key=\"d0\">4</data></node><node id=\"n4\"><data key=\"d3\">0</data><data key=\"d4\">1</data><data key=\"d0\">5</data><data key=\"d1\">OUTP</data><data key=\"d2\">LinearActivation</data></node><edge id=\"e0\" source=\"n0\" target=\"n2\"><data key=\"d5\">-1</data><data key=\"d6\">1</data><data key=\"d7\">3</data></edge><edge id=\"e1\" source=\"n0\" target=\"n3\"><data key=\"d5\">0.5</data><data key=\"d6\">1</data><data key=\"d7\">4</data></edge><edge id=\"e2\" source=\"n1\" target=\"n2\"><data key=\"d5\">1.5</data><data key=\"d6\">2</data><data key=\"d7\">3</data></edge><edge id=\"e3\" source=\"n1\" target=\"n3\"><data key=\"d5\">-0.5</data><data key=\"d6\">2</data><data key=\"d7\">4</data></edge><edge id=\"e4\" source=\"n2\" target=\"n4\"><data key=\"d7\">5</data><data key=\"d5\">0.5</data><data key=\"d6\">3</data></edge><edge id=\"e5\" source=\"n3\" target=\"n4\"><data key=\"d5\">0.5</data><data key=\"d6\">4</data><data key=\"d7\">5</data></edge></graph></graphml>"
+const graphXml = "<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:schemaLocation=\"http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd\"><desc>test marshal graph</desc><key id=\"d0\" for=\"node\" attr.name=\"NodeActivationType\" attr.type=\"string\"></key><key id=\"d1\" for=\"node\" attr.name=\"NodeNeuronType\" attr.type=\"string\"></key><key id=\"d2\" for=\"node\" attr.name=\"X\" attr.type=\"double\"></key><key id=\"d3\" for=\"node\" attr.name=\"Y\" attr.type=\"double\"></key><key id=\"d4\" for=\"node\" attr.name=\"Z\" attr.type=\"double\"></key><key id=\"d5\" for=\"node\" attr.name=\"id\" attr.type=\"int\"></key><key id=\"d6\" for=\"edge\" attr.name=\"sourceId\" attr.type=\"int\"></key><key id=\"d7\" for=\"edge\" attr.name=\"targetId\" attr.type=\"int\"></key><key id=\"d8\" for=\"edge\" attr.name=\"weight\" attr.type=\"double\"></key><graph id=\"g0\" edgedefault=\"directed\"><node id=\"n0\"><data key=\"d0\">NullActivation</data><data key=\"d1\">INPT</data><data key=\"d2\">-0.5</data><data key=\"d3\">-1</data><data key=\"d4\">-0.5</data><data key=\"d5\">1</data></node><node id=\"n1\"><data key=\"d0\">NullActivation</data><data key=\"d1\">INPT</data><data key=\"d2\">0.5</data><data key=\"d3\">-1</data><data key=\"d4\">-0.5</data><data key=\"d5\">2</data></node><node id=\"n2\"><data key=\"d0\">SigmoidSteepenedActivation</data><data key=\"d1\">HIDN</data><data key=\"d2\">0</data><data key=\"d3\">0</data><data key=\"d4\">0</data><data key=\"d5\">3</data></node><node id=\"n3\"><data key=\"d0\">SigmoidSteepenedActivation</data><data key=\"d1\">HIDN</data><data key=\"d2\">0</data><data key=\"d3\">0</data><data key=\"d4\">0</data><data key=\"d5\">4</data></node><node id=\"n4\"><data key=\"d0\">LinearActivation</data><data key=\"d1\">OUTP</data><data key=\"d2\">0</data><data key=\"d3\">1</data><data key=\"d4\">0.5</data><data key=\"d5\">5</data></node><edge id=\"e0\" source=\"n0\" target=\"n2\"><data key=\"d6\">1</data><data key=\"d7\">3</data><data key=\"d8\">-1</data></edge><edge id=\"e1\" source=\"n0\" target=\"n3\"><data key=\"d6\">1</data><data key=\"d7\">4</data><data key=\"d8\">0.5</data></edge><edge id=\"e2\" source=\"n1\" target=\"n2\"><data key=\"d6\">2</data><data key=\"d7\">3</data><data key=\"d8\">1.5</data></edge><edge id=\"e3\" source=\"n1\" target=\"n3\"><data key=\"d6\">2</data><data key=\"d7\">4</data><data key=\"d8\">-0.5</data></edge><edge id=\"e4\" source=\"n2\" target=\"n4\"><data key=\"d6\">3</data><data key=\"d7\">5</data><data key=\"d8\">0.5</data></edge><edge id=\"e5\" source=\"n3\" target=\"n4\"><data key=\"d6\">4</data><data key=\"d7\">5</data><data key=\"d8\">0.5</data></edge></graph></graphml>"
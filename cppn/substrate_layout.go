@@ -3,6 +3,7 @@ package cppn
 import (
 	"errors"
 	"fmt"
+	neatmath "github.com/yaricom/goNEAT/v4/neat/math"
 	"github.com/yaricom/goNEAT/v4/neat/network"
 )
 
@@ -21,6 +22,24 @@ type SubstrateLayout interface {
 	OutputCount() int
 }
 
+// NodeActivationLayout Defines the layout which can define the activation functions of particular nodes
+type NodeActivationLayout interface {
+	// NodeActivation Returns the activation function of the neuron with specified index and type, and true if it is
+	// defined by the layout
+	NodeActivation(index int, nType network.NodeNeuronType) (neatmath.NodeActivationType, bool)
+}
+
+// Returns the activation function defined by the layout for the neuron with specified index and type if the layout
+// implements NodeActivationLayout, or the given default activation otherwise
+func layoutActivation(layout interface{}, index int, nType network.NodeNeuronType, defaultActivation neatmath.NodeActivationType) neatmath.NodeActivationType {
+	if activationLayout, ok := layout.(NodeActivationLayout); ok {
+		if activation, ok := activationLayout.NodeActivation(index, nType); ok {
+			return activation
+		}
+	}
+	return defaultActivation
+}
+
 // GridSubstrateLayout Defines grid substrate layout
 type GridSubstrateLayout struct {
 	// The number of bias nodes encoded in this substrate
@@ -38,6 +57,9 @@ type GridSubstrateLayout struct {
 	hiddenDelta float64
 	// The output coordinates increment
 	outputDelta float64
+
+	// The flag to indicate whether layers are placed on distinct Z planes
+	zPlanes bool
 }
 
 // NewGridSubstrateLayout Creates new instance with specified number of nodes to create layout for
@@ -58,6 +80,15 @@ func NewGridSubstrateLayout(biasCount, inputCount, outputCount, hiddenCount int)
 	return &s
 }
 
+// NewGridSubstrateLayout3D Creates new instance with specified number of nodes to create three-dimensional layout for.
+// The layers of input, hidden, and output nodes are placed on distinct Z planes at -1, 0, and 1 respectively, and
+// nodes of each layer are evenly distributed along X axis with Y = 0.
+func NewGridSubstrateLayout3D(biasCount, inputCount, outputCount, hiddenCount int) *GridSubstrateLayout {
+	s := NewGridSubstrateLayout(biasCount, inputCount, outputCount, hiddenCount)
+	s.zPlanes = true
+	return s
+}
+
 func (g *GridSubstrateLayout) NodePosition(index int, nType network.NodeNeuronType) (*PointF, error) {
 	if index < 0 {
 		return nil, errors.New("neuron index can not be negative")
@@ -95,6 +126,11 @@ func (g *GridSubstrateLayout) NodePosition(index int, nType network.NodeNeuronTy
 	point.X = -1.0 + delta/2.0 // the initial position with a half-delta shift
 	point.X += float64(index) * delta
 
+	if g.zPlanes {
+		// move the layer from Y coordinate to the Z plane
+		point.Z, point.Y = point.Y, 0.0
+	}
+
 	return &point, nil
 }
 
@@ -115,7 +151,7 @@ func (g *GridSubstrateLayout) OutputCount() int {
 }
 
 func (g *GridSubstrateLayout) String() string {
-	str := fmt.Sprintf("GridSubstrateLayout:\n\tINPT: %d\n\tHIDN: %d\n\tOUTP: %d\n\tBIAS: %d",
-		g.inputCount, g.hiddenCount, g.outputCount, g.biasCount)
+	str := fmt.Sprintf("GridSubstrateLayout:\n\tINPT: %d\n\tHIDN: %d\n\tOUTP: %d\n\tBIAS: %d\n\t3D: %t",
+		g.inputCount, g.hiddenCount, g.outputCount, g.biasCount, g.zPlanes)
 	return str
 }
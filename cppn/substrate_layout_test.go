@@ -1,6 +1,7 @@
 package cppn
 
 import (
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/yaricom/goNEAT/v4/neat/network"
 	"testing"
@@ -36,6 +37,27 @@ func TestGridSubstrateLayout_NodePosition(t *testing.T) {
 	require.Nil(t, outputPos, "nil expected")
 }
 
+func TestGridSubstrateLayout3D_NodePosition(t *testing.T) {
+	layout := NewGridSubstrateLayout3D(1, 4, 2, 2)
+
+	testCases := []struct {
+		nType    network.NodeNeuronType
+		expected []PointF
+	}{
+		{nType: network.BiasNeuron, expected: []PointF{{X: 0.0, Y: 0.0, Z: 0.0}}},
+		{nType: network.InputNeuron, expected: []PointF{{X: -0.75, Z: -1.0}, {X: -0.25, Z: -1.0}, {X: 0.25, Z: -1.0}, {X: 0.75, Z: -1.0}}},
+		{nType: network.HiddenNeuron, expected: []PointF{{X: -0.5, Z: 0.0}, {X: 0.5, Z: 0.0}}},
+		{nType: network.OutputNeuron, expected: []PointF{{X: -0.5, Z: 1.0}, {X: 0.5, Z: 1.0}}},
+	}
+	for _, tc := range testCases {
+		for i, expected := range tc.expected {
+			pos, err := layout.NodePosition(i, tc.nType)
+			require.NoError(t, err)
+			assert.Equal(t, expected, *pos, "wrong position of %s node at: %d", network.NeuronTypeName(tc.nType), i)
+		}
+	}
+}
+
 func checkNeuronLayoutPositions(positions []float64, nType network.NodeNeuronType, layout SubstrateLayout, t *testing.T) {
 	count := len(positions) / 2
 	for i := 0; i < count; i++ {
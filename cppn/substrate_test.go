@@ -2,11 +2,14 @@ package cppn
 
 import (
 	"bytes"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
 	"github.com/yaricom/goNEAT/v4/neat/math"
+	"github.com/yaricom/goNEAT/v4/neat/network"
 	"testing"
+	"time"
 )
 
 const hyperNeatTestConfigFile = "../data/test/test_hyper.neat.yml"
@@ -48,7 +51,7 @@ func TestSubstrate_CreateNetworkSolver(t *testing.T) {
 	assert.Equal(t, totalLinkCount, solver.LinkCount(), "wrong links number")
 
 	// test outputs
-	outExpected := []float64{1.0768005629123314, 1.131042391465084}
+	outExpected := []float64{1.0250491652984794, 1.5100754688624802}
 	checkNetworkSolverOutputs(solver, outExpected, 0.0, t)
 }
 
@@ -78,11 +81,11 @@ func TestSubstrate_CreateLEONetworkSolver(t *testing.T) {
 	totalNodeCount := biasCount + inputCount + hiddenCount + outputCount
 	assert.Equal(t, totalNodeCount, solver.NodeCount(), "wrong nodes number")
 
-	totalLinkCount := 16
+	totalLinkCount := 15
 	assert.Equal(t, totalLinkCount, solver.LinkCount(), "wrong links number")
 
 	// test outputs
-	outExpected := []float64{-0.1147701149321737, -0.7584174401602518}
+	outExpected := []float64{1.5931849794411725, -0.4717448232351531}
 	checkNetworkSolverOutputs(solver, outExpected, 1e-5, t)
 }
 
@@ -121,13 +124,374 @@ func TestSubstrate_CreateNetworkSolverWithGraphBuilder(t *testing.T) {
 	require.NoError(t, err, "failed to marshal graph")
 
 	strOut := buf.String()
-	assert.Equal(t, 5587, len(strOut), "wrong length of marshalled string")
+	assert.Equal(t, 6492, len(strOut), "wrong length of marshalled string")
 
 	// test outputs
-	outExpected := []float64{1.0768005629123314, 1.131042391465084}
+	outExpected := []float64{1.0250491652984794, 1.5100754688624802}
 	checkNetworkSolverOutputs(solver, outExpected, 0.0, t)
 }
 
+func TestSubstrate_CreateNetworkSolver_EncoderWidthMismatch(t *testing.T) {
+	layout := NewGridSubstrateLayout(1, 4, 2, 2)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	substr.Encoder = NewCoordinateEncoder(false, DeltaEncoder{})
+
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+
+	solver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	assert.EqualError(t, err, "CPPN inputs number [6] or sensors number [7] does not match the coordinate encoder width [9]")
+	assert.Nil(t, solver)
+}
+
+func TestSubstrate_CreateNetworkSolver_NodeBias(t *testing.T) {
+	inputCount, hiddenCount, outputCount := 4, 2, 2
+	layout := NewGridSubstrateLayout(0, inputCount, outputCount, hiddenCount)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+
+	noBiasSolver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+
+	context.NodeBiasEnabled = true
+	solver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+
+	// the implicit BIAS neuron expected
+	totalNodeCount := 1 + inputCount + hiddenCount + outputCount
+	assert.Equal(t, totalNodeCount, solver.NodeCount(), "wrong nodes number")
+	// the bias for each hidden and output node expected
+	totalLinkCount := noBiasSolver.LinkCount() + hiddenCount + outputCount
+	assert.Equal(t, totalLinkCount, solver.LinkCount(), "wrong links number")
+
+	// check that biases affect outputs
+	outs := activateForward(solver, t)
+	noBiasOuts := activateForward(noBiasSolver, t)
+	assert.NotEqual(t, noBiasOuts, outs)
+}
+
+func TestSubstrate_CreateNetworkSolver_NodeBiasOutputOutOfRange(t *testing.T) {
+	layout := NewGridSubstrateLayout(1, 4, 2, 2)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+	context.NodeBiasEnabled = true
+	context.NodeBiasOutput = 1
+
+	solver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	assert.EqualError(t, err, "node bias output index [1] is out of CPPN outputs range [1]")
+	assert.Nil(t, solver)
+}
+
+func TestSubstrate_CreateNetworkSolver_NodeActivatorsPalette(t *testing.T) {
+	inputCount, hiddenCount, outputCount := 4, 2, 2
+	layout := NewGridSubstrateLayout(1, inputCount, outputCount, hiddenCount)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := FastSolverFromGenomeFile(cppnLeoHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+	palette := []math.NodeActivationType{math.GaussianBipolarActivation, math.TanhActivation}
+	context.NodeActivatorsPalette.ActivationTypes = palette
+
+	builder := NewSubstrateGraphMLBuilder("", false)
+	solver, err := substr.CreateNetworkSolver(cppn, false, builder, context)
+	require.NoError(t, err, "failed to create network solver")
+	require.NotNil(t, solver)
+
+	// check that activation of each output and hidden node is selected by the CPPN outputs at the node position
+	activations := graphNodeActivations(builder, t)
+	firstOutput := 1 + inputCount
+	for i := firstOutput; i < firstOutput+outputCount+hiddenCount; i++ {
+		neuronType, index := network.OutputNeuron, i-firstOutput
+		if index >= outputCount {
+			neuronType, index = network.HiddenNeuron, index-outputCount
+		}
+		position, err := layout.NodePosition(index, neuronType)
+		require.NoError(t, err, "failed to get node position")
+		outs, err := queryCPPN([]float64{position.X, position.Y, position.Z, 0, 0, 0}, cppn)
+		require.NoError(t, err, "failed to query CPPN")
+		expected := palette[0]
+		if outs[1] > outs[0] {
+			expected = palette[1]
+		}
+		name, err := math.NodeActivators.ActivationNameFromType(expected)
+		require.NoError(t, err)
+		assert.Equal(t, name, activations[i], "wrong activation of node: %d", i)
+	}
+}
+
+func TestSubstrate_CreateNetworkSolver_NodeActivatorsOutOfRange(t *testing.T) {
+	layout := NewGridSubstrateLayout(1, 4, 2, 2)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+	context.NodeActivatorsPalette.ActivationTypes = []math.NodeActivationType{math.SigmoidSteepenedActivation, math.TanhActivation}
+
+	solver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	assert.EqualError(t, err, "node activators outputs [0, 2) are out of CPPN outputs range [1]")
+	assert.Nil(t, solver)
+}
+
+func TestSubstrate_CreateNetworkSolver_Plasticity(t *testing.T) {
+	biasCount, inputCount, hiddenCount, outputCount := 1, 4, 2, 2
+	layout := NewGridSubstrateLayout(biasCount, inputCount, outputCount, hiddenCount)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	fastCppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	// the CPPN outputs of the learning rule follow the weight output
+	cppn := &extraOutputsSolver{Solver: fastCppn, extra: []float64{0.5, 0.0, 0.0, 0.1, 0.2}}
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+
+	staticSolver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+
+	context.PlasticityEnabled = true
+	context.PlasticityOutput = 1
+	solver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+	plastic, ok := solver.(*PlasticNetworkSolver)
+	require.True(t, ok, "plastic network solver expected")
+	assert.Equal(t, staticSolver.NodeCount(), plastic.NodeCount(), "wrong nodes number")
+	assert.Equal(t, staticSolver.LinkCount(), plastic.LinkCount(), "wrong links number")
+	expectedRule := HebbianRule{A: 0.5, B: 0.0, C: 0.0, D: 0.1, LearningRate: 0.2}
+	for _, rule := range plastic.Rules() {
+		assert.Equal(t, expectedRule, rule)
+	}
+
+	// check that weights are updated online during activation
+	initialWeights := plastic.Weights()
+	outs := activateForward(solver, t)
+	staticOuts := activateForward(staticSolver, t)
+	assert.NotEqual(t, staticOuts, outs)
+	assert.NotEqual(t, initialWeights, plastic.Weights())
+
+	plastic.ResetWeights()
+	assert.Equal(t, initialWeights, plastic.Weights())
+}
+
+func TestSubstrate_CreateNetworkSolver_PlasticityOutputOutOfRange(t *testing.T) {
+	layout := NewGridSubstrateLayout(1, 4, 2, 2)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+	context.PlasticityEnabled = true
+
+	solver, err := substr.CreateNetworkSolver(cppn, false, nil, context)
+	assert.EqualError(t, err, "plasticity outputs [0, 5) are out of CPPN outputs range [1]")
+	assert.Nil(t, solver)
+}
+
+func TestSubstrate_CreateNetworkSolver_Neuromodulation(t *testing.T) {
+	biasCount, inputCount, hiddenCount, outputCount := 1, 4, 2, 2
+	layout := NewGridSubstrateLayout(biasCount, inputCount, outputCount, hiddenCount)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	fastCppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	// the learning rule outputs followed by the output deciding that all hidden nodes are modulatory
+	cppn := &extraOutputsSolver{Solver: fastCppn, extra: []float64{0.5, 0.0, 0.0, 0.1, 0.2, 1.0}}
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+	context.PlasticityEnabled = true
+	context.PlasticityOutput = 1
+	context.NeuromodulationEnabled = true
+	context.NeuromodulationOutput = 6
+
+	builder := NewSubstrateGraphMLBuilder("", false)
+	solver, err := substr.CreateNetworkSolver(cppn, false, builder, context)
+	require.NoError(t, err, "failed to create network solver")
+	plastic, ok := solver.(*PlasticNetworkSolver)
+	require.True(t, ok, "plastic network solver expected")
+
+	// check that only hidden nodes are modulatory
+	firstHidden := biasCount + inputCount + outputCount
+	functions := plastic.NodeFunctions()
+	require.Len(t, functions, firstHidden+hiddenCount)
+	graph, err := builder.(*graphMLBuilder).graph()
+	require.NoError(t, err, "failed to get graph")
+	for _, gNode := range graph.Nodes {
+		attributes, err := gNode.GetAttributes()
+		require.NoError(t, err, "failed to get node attributes")
+		id := attributes[nodeAttrID].(int)
+		expected := NodeFunctionStandard
+		if id >= firstHidden {
+			expected = NodeFunctionModulatory
+		}
+		assert.Equal(t, expected, functions[id], "wrong function of node: %d", id)
+		assert.Equal(t, string(expected), attributes[nodeAttrNodeFunctionType], "wrong function of graph node: %d", id)
+	}
+
+	// the neuromodulation output is out of range
+	context.NeuromodulationOutput = 7
+	_, err = substr.CreateNetworkSolver(cppn, false, nil, context)
+	assert.EqualError(t, err, "neuromodulation output index [7] is out of CPPN outputs range [7]")
+
+	// the neuromodulation without plasticity
+	context.PlasticityEnabled = false
+	_, err = substr.CreateNetworkSolver(cppn, false, nil, context)
+	assert.EqualError(t, err, "neuromodulation requires plasticity to be enabled")
+}
+
+func TestSubstrate_CreateNetworkSolverContext(t *testing.T) {
+	layout := NewGridSubstrateLayout(1, 4, 2, 2)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	fastCppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	options, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+
+	// the context is not cancelled
+	solver, err := substr.CreateNetworkSolverContext(context.Background(), fastCppn, false, nil, options)
+	require.NoError(t, err, "failed to create network solver")
+	assert.NotNil(t, solver)
+
+	// the context cancelled during CPPN queries
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cppn := &cancellingSolver{Solver: fastCppn, cancel: cancel, after: 2}
+	solver, err = substr.CreateNetworkSolverContext(ctx, cppn, false, nil, options)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, solver)
+	assert.Equal(t, 2, cppn.loads, "no queries expected after cancellation")
+
+	// the context deadline exceeded before creation
+	ctx, cancelDeadline := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelDeadline()
+	solver, err = substr.CreateNetworkSolverContext(ctx, fastCppn, false, nil, options)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, solver)
+}
+
+func TestSubstrate_CreateNetworkSolver_Layered(t *testing.T) {
+	layout := createTestLayeredLayout(t)
+	fastCppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+
+	testCases := []struct {
+		name        string
+		connections []LayerConnection
+		queries     int
+	}{
+		{name: "sequential", queries: 2*2 + 2*1 + 1*1},
+		{name: "skip", connections: append(layout.SequentialConnections(), LayerConnection{Source: "retina", Target: "h2"}),
+			queries: 2*2 + 2*1 + 1*1 + 2*1},
+		{name: "recurrent", connections: []LayerConnection{
+			{Source: "retina", Target: "h2"}, {Source: "h2", Target: "h1"}, {Source: "h1", Target: "decision"}},
+			queries: 2*1 + 1*2 + 2*1},
+	}
+	for _, tc := range testCases {
+		substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+		substr.LinkExpression = ThresholdLinkExpression{Mapping: LinearWeightMapping{WeightRange: 1.0}}
+		substr.Connections = tc.connections
+		cppn := &recordingSolver{Solver: fastCppn}
+		graph := NewSubstrateGraphMLBuilder("", false)
+		solver, err := substr.CreateNetworkSolver(cppn, false, graph, context)
+		require.NoError(t, err, "failed to create network solver: %s", tc.name)
+		assert.Equal(t, 6, solver.NodeCount(), tc.name)
+		assert.Equal(t, tc.queries, solver.LinkCount(), tc.name)
+		assert.Len(t, cppn.inputs, tc.queries, tc.name)
+		edges, err := graph.EdgesCount()
+		require.NoError(t, err)
+		assert.Equal(t, tc.queries, edges, tc.name)
+	}
+
+	// check the coordinates of the skip connection queried last
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	substr.Connections = []LayerConnection{{Source: "retina", Target: "h2"}, {Source: "h2", Target: "decision"}}
+	cppn := &recordingSolver{Solver: fastCppn}
+	_, err = substr.CreateNetworkSolver(cppn, false, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+	expected := [][]float64{
+		encodeCoordinates(substr.Encoder, PointF{X: -0.5, Y: -1.0}, PointF{X: 0.0, Y: 0.5}),
+		encodeCoordinates(substr.Encoder, PointF{X: 0.5, Y: -1.0}, PointF{X: 0.0, Y: 0.5}),
+		encodeCoordinates(substr.Encoder, PointF{X: 0.0, Y: 0.5}, PointF{X: 0.0, Y: 1.0}),
+	}
+	assert.Equal(t, expected, cppn.inputs)
+
+	// the invalid connection plan
+	substr.Connections = []LayerConnection{{Source: "decision", Target: "retina"}}
+	solver, err := substr.CreateNetworkSolver(fastCppn, false, nil, context)
+	assert.EqualError(t, err, "the input layer can not be the target of connection: decision -> retina")
+	assert.Nil(t, solver)
+
+	// the default connection plan of layers declared out of order of their types
+	layout, err = NewLayeredSubstrateLayout(0, []SubstrateLayer{
+		{Name: "left", Type: network.InputNeuron, Sheet: NewLineSheet(1, -1.0)},
+		{Name: "right", Type: network.InputNeuron, Sheet: NewLineSheet(1, -0.5)},
+		{Name: "decision", Type: network.OutputNeuron, Sheet: NewLineSheet(1, 1.0)},
+		{Name: "hidden", Type: network.HiddenNeuron, Sheet: NewLineSheet(2, 0.0)},
+	})
+	require.NoError(t, err, "failed to create layout")
+	substr = NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	substr.LinkExpression = ThresholdLinkExpression{Mapping: LinearWeightMapping{WeightRange: 1.0}}
+	cppn = &recordingSolver{Solver: fastCppn}
+	solver, err = substr.CreateNetworkSolver(cppn, false, nil, context)
+	require.NoError(t, err, "failed to create network solver")
+	assert.Equal(t, 5, solver.NodeCount())
+	// left→hidden, right→hidden, hidden→decision
+	assert.Equal(t, 1*2+1*2+2*1, solver.LinkCount())
+	assert.Len(t, cppn.inputs, 1*2+1*2+2*1)
+}
+
+func TestSubstrate_CreateNetworkSolver_3D(t *testing.T) {
+	layout := NewGridSubstrateLayout3D(0, 2, 1, 1)
+	substr := NewSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+
+	cppn, err := FastSolverFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadHyperNeatContext(hyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to load HyperNEAT context options")
+	context.LinkThreshold = 0
+
+	recorder := &recordingSolver{Solver: cppn}
+	builder := NewSubstrateGraphMLBuilder("", false)
+	solver, err := substr.CreateNetworkSolver(recorder, false, builder, context)
+	require.NoError(t, err, "failed to create network solver")
+	require.NotNil(t, solver)
+
+	// check the exact hypercube coordinates passed to CPPN: (x1, y1, z1, x2, y2, z2)
+	expected := [][]float64{
+		{-0.5, 0.0, -1.0, 0.0, 0.0, 0.0}, // input 0 -> hidden
+		{0.5, 0.0, -1.0, 0.0, 0.0, 0.0},  // input 1 -> hidden
+		{0.0, 0.0, 0.0, 0.0, 0.0, 1.0},   // hidden -> output
+	}
+	assert.Equal(t, expected, recorder.inputs)
+
+	// check that Z coordinates stored in the graph
+	graph, err := builder.(*graphMLBuilder).graph()
+	require.NoError(t, err, "failed to get graph")
+	zPlanes := make(map[string][]float64)
+	for _, gNode := range graph.Nodes {
+		attributes, err := gNode.GetAttributes()
+		require.NoError(t, err, "failed to get node attributes")
+		nType := attributes[nodeAttrNodeNeuronType].(string)
+		zPlanes[nType] = append(zPlanes[nType], attributes[nodeAttrZ].(float64))
+	}
+	assert.Equal(t, map[string][]float64{"INPT": {-1.0, -1.0}, "HIDN": {0.0}, "OUTP": {1.0}}, zPlanes)
+}
+
 // Loads HyperNeat context from provided config file's path
 func loadHyperNeatContext(configPath string) (*hyperneat.Options, error) {
 	if context, err := hyperneat.LoadYAMLConfigFile(configPath); err != nil {
@@ -0,0 +1,85 @@
+package cppn
+
+import (
+	"github.com/pkg/errors"
+	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// VarianceFunction Estimates the variance of CPPN outputs within the region of the given quadtree node. This variance
+// is a heuristic indicator of a region's heterogeneity (i.e., presence of information), which is used to decide whether
+// to divide the region further and whether to express connections from it.
+type VarianceFunction func(node *QuadNode) float64
+
+// NewVarianceFunction Creates the variance function defined by provided options. By default, the variance of the CPPN
+// weight output over the direct children of the quadtree node is estimated.
+func NewVarianceFunction(options *eshyperneat.Options) (VarianceFunction, error) {
+	var values func(node *QuadNode) []float64
+	switch options.VarianceOutput {
+	case "", eshyperneat.VarianceOutputWeight:
+		values = func(node *QuadNode) []float64 {
+			return []float64{node.Weight()}
+		}
+	case eshyperneat.VarianceOutputLeoWeighted:
+		values = func(node *QuadNode) []float64 {
+			return []float64{node.Weight() * node.Leo()}
+		}
+	case eshyperneat.VarianceOutputAll:
+		values = func(node *QuadNode) []float64 {
+			return node.CppnOut
+		}
+	default:
+		return nil, errors.Errorf("unsupported variance output: %s", options.VarianceOutput)
+	}
+
+	switch options.VarianceFunction {
+	case "", eshyperneat.VarianceChildren:
+		return func(node *QuadNode) float64 {
+			return outputsVariance(node.Nodes, values)
+		}, nil
+	case eshyperneat.VarianceSubtree:
+		return func(node *QuadNode) float64 {
+			if len(node.Nodes) == 0 {
+				return 0.0
+			}
+			return outputsVariance(nodeLeaves(node), values)
+		}, nil
+	default:
+		return nil, errors.Errorf("unsupported variance function: %s", options.VarianceFunction)
+	}
+}
+
+// Returns the sum of variances of each CPPN output value among provided quadtree nodes. The values of each node are
+// produced by the given function.
+func outputsVariance(nodes []*QuadNode, values func(node *QuadNode) []float64) float64 {
+	// quick check
+	if len(nodes) < 2 {
+		return 0.0
+	}
+	columns := make([][]float64, 0)
+	for i, node := range nodes {
+		for j, value := range values(node) {
+			if j == len(columns) {
+				columns = append(columns, make([]float64, len(nodes)))
+			}
+			columns[j][i] = value
+		}
+	}
+	variance := 0.0
+	for _, column := range columns {
+		variance += stat.Variance(column, nil)
+	}
+	return variance
+}
+
+// Collects the leaves of the subtree of a given quadtree node, or the node itself if it has no children
+func nodeLeaves(n *QuadNode) []*QuadNode {
+	if len(n.Nodes) == 0 {
+		return []*QuadNode{n}
+	}
+	leaves := make([]*QuadNode, 0, len(n.Nodes))
+	for _, p := range n.Nodes {
+		leaves = append(leaves, nodeLeaves(p)...)
+	}
+	return leaves
+}
@@ -0,0 +1,97 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goESHyperNEAT/v2/eshyperneat"
+	"github.com/yaricom/goNEAT/v4/neat/math"
+	"testing"
+)
+
+func TestNewVarianceFunction(t *testing.T) {
+	testCases := []struct {
+		name     string
+		function eshyperneat.VarianceFunctionType
+		output   eshyperneat.VarianceOutputType
+		expected float64
+	}{
+		{name: "default", expected: 1.6666666666666667},
+		{name: "children weight", function: eshyperneat.VarianceChildren, output: eshyperneat.VarianceOutputWeight, expected: 1.6666666666666667},
+		{name: "children LEO weighted", function: eshyperneat.VarianceChildren, output: eshyperneat.VarianceOutputLeoWeighted, expected: 1.0},
+		{name: "children all outputs", function: eshyperneat.VarianceChildren, output: eshyperneat.VarianceOutputAll, expected: 2.0},
+		{name: "subtree weight", function: eshyperneat.VarianceSubtree, output: eshyperneat.VarianceOutputWeight, expected: 1.2380952380952381},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := buildTree()
+			// set LEO outputs of the root children
+			for i, leo := range []float64{1, 0, 1, 0} {
+				root.Nodes[i].CppnOut = append(root.Nodes[i].CppnOut, leo)
+			}
+
+			variance, err := NewVarianceFunction(&eshyperneat.Options{VarianceFunction: tc.function, VarianceOutput: tc.output})
+			require.NoError(t, err, "failed to create variance function")
+			assert.InDelta(t, tc.expected, variance(root), 1e-15)
+
+			// the leaf node has no variance
+			assert.Zero(t, variance(root.Nodes[1]))
+		})
+	}
+}
+
+func TestNewVarianceFunction_Unsupported(t *testing.T) {
+	_, err := NewVarianceFunction(&eshyperneat.Options{VarianceFunction: "unknown"})
+	assert.EqualError(t, err, "unsupported variance function: unknown")
+
+	_, err = NewVarianceFunction(&eshyperneat.Options{VarianceOutput: "unknown"})
+	assert.EqualError(t, err, "unsupported variance output: unknown")
+}
+
+func TestNodeLeaves(t *testing.T) {
+	root := buildTree()
+	leaves := nodeLeaves(root)
+	require.Len(t, leaves, 7)
+	for _, leaf := range leaves {
+		assert.Empty(t, leaf.Nodes, "not a leaf node: %s", leaf)
+	}
+
+	// the leaf node is the only leaf of itself
+	assert.Equal(t, []*QuadNode{root.Nodes[1]}, nodeLeaves(root.Nodes[1]))
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_Variance(t *testing.T) {
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+
+	// the variance function defined by options
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	context.VarianceFunction = eshyperneat.VarianceSubtree
+	solver, err := substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	require.NotNil(t, solver)
+
+	// the custom variance function takes precedence over options
+	layout, err = NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr = NewEvolvableSubstrate(layout, math.SigmoidSteepenedActivation, math.LinearActivation)
+	defaultVariance, err := NewVarianceFunction(context)
+	require.NoError(t, err, "failed to create variance function")
+	calls := 0
+	substr.Variance = func(node *QuadNode) float64 {
+		calls++
+		return defaultVariance(node)
+	}
+	context.VarianceFunction = "unknown"
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	require.NoError(t, err, "failed to create solver")
+	assert.True(t, calls > 0, "custom variance function not used")
+
+	// the unsupported variance function defined by options
+	substr.Variance = nil
+	_, err = substr.CreateNetworkSolver(cppn, nil, context)
+	assert.EqualError(t, err, "unsupported variance function: unknown")
+}
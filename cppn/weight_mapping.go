@@ -0,0 +1,120 @@
+package cppn
+
+import (
+	"github.com/pkg/errors"
+	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
+	"math"
+)
+
+// WeightMapping Defines the function to map the CPPN weight output into the weight of the substrate link
+type WeightMapping interface {
+	// MapWeight Returns the weight of the substrate link for the given CPPN weight output
+	MapWeight(cppnOutput float64) float64
+}
+
+// LinearWeightMapping Scales the CPPN output to fit WeightRange
+type LinearWeightMapping struct {
+	// WeightRange The range of the link weights
+	WeightRange float64
+}
+
+func (l LinearWeightMapping) MapWeight(cppnOutput float64) float64 {
+	return cppnOutput * l.WeightRange
+}
+
+// ThresholdNormalizedWeightMapping Normalizes the magnitude of the CPPN output exceeding Threshold into the range
+// [0, 1] and scales it to fit WeightRange preserving sign. The outputs with magnitude below Threshold are mapped to zero.
+type ThresholdNormalizedWeightMapping struct {
+	// Threshold The link threshold value
+	Threshold float64
+	// WeightRange The range of the link weights
+	WeightRange float64
+}
+
+func (t ThresholdNormalizedWeightMapping) MapWeight(cppnOutput float64) float64 {
+	if math.Abs(cppnOutput) < t.Threshold {
+		return 0
+	}
+	weight := (math.Abs(cppnOutput) - t.Threshold) / (1 - t.Threshold) // normalize [0, 1]
+	weight *= t.WeightRange                                            // scale to fit a given weight range
+	if math.Signbit(cppnOutput) {
+		weight *= -1 // restore sign
+	}
+	return weight
+}
+
+// TanhWeightMapping Squashes the CPPN output multiplied by Gain with hyperbolic tangent and scales it to fit WeightRange
+type TanhWeightMapping struct {
+	// Gain The multiplier of the CPPN output before squashing
+	Gain float64
+	// WeightRange The range of the link weights
+	WeightRange float64
+}
+
+func (t TanhWeightMapping) MapWeight(cppnOutput float64) float64 {
+	return math.Tanh(t.Gain*cppnOutput) * t.WeightRange
+}
+
+// PowerWeightMapping Raises the magnitude of the CPPN output clamped to [-1, 1] to the power of Exponent and scales it
+// to fit WeightRange preserving sign. With Exponent > 1 the strong weights are emphasized relative to the weak ones.
+type PowerWeightMapping struct {
+	// Exponent The exponent of the power law
+	Exponent float64
+	// WeightRange The range of the link weights
+	WeightRange float64
+}
+
+func (p PowerWeightMapping) MapWeight(cppnOutput float64) float64 {
+	weight := math.Pow(math.Min(math.Abs(cppnOutput), 1.0), p.Exponent) * p.WeightRange
+	if math.Signbit(cppnOutput) {
+		weight *= -1 // restore sign
+	}
+	return weight
+}
+
+// QuantizedWeightMapping Maps the CPPN output clamped to [-1, 1] to the nearest of Levels discrete weights evenly
+// spaced within [-WeightRange, WeightRange].
+type QuantizedWeightMapping struct {
+	// Levels The number of discrete weight levels, at least two
+	Levels int
+	// WeightRange The range of the link weights
+	WeightRange float64
+}
+
+func (q QuantizedWeightMapping) MapWeight(cppnOutput float64) float64 {
+	value := math.Max(-1.0, math.Min(cppnOutput, 1.0))
+	steps := float64(q.Levels - 1)
+	level := math.Round((value + 1.0) / 2.0 * steps)
+	return (level*2.0/steps - 1.0) * q.WeightRange
+}
+
+// NewWeightMapping Creates the weight mapping function defined by provided options. Returns nil if the type of
+// weight mapping is not set, i.e., the default mapping of the link expression strategy should be used.
+func NewWeightMapping(options *hyperneat.Options) (WeightMapping, error) {
+	switch options.WeightMapping {
+	case "":
+		return nil, nil
+	case hyperneat.WeightMappingLinear:
+		return LinearWeightMapping{WeightRange: options.WeightRange}, nil
+	case hyperneat.WeightMappingThresholdNormalized:
+		return ThresholdNormalizedWeightMapping{Threshold: options.LinkThreshold, WeightRange: options.WeightRange}, nil
+	case hyperneat.WeightMappingTanh:
+		gain := options.WeightMappingGain
+		if gain == 0 {
+			gain = 1.0
+		}
+		return TanhWeightMapping{Gain: gain, WeightRange: options.WeightRange}, nil
+	case hyperneat.WeightMappingPower:
+		if options.WeightMappingExponent <= 0 {
+			return nil, errors.Errorf("weight mapping exponent must be positive, got: %f", options.WeightMappingExponent)
+		}
+		return PowerWeightMapping{Exponent: options.WeightMappingExponent, WeightRange: options.WeightRange}, nil
+	case hyperneat.WeightMappingQuantized:
+		if options.WeightQuantizationLevels < 2 {
+			return nil, errors.Errorf("weight quantization levels must be at least 2, got: %d", options.WeightQuantizationLevels)
+		}
+		return QuantizedWeightMapping{Levels: options.WeightQuantizationLevels, WeightRange: options.WeightRange}, nil
+	default:
+		return nil, errors.Errorf("unsupported weight mapping: %s", options.WeightMapping)
+	}
+}
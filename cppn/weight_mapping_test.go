@@ -0,0 +1,119 @@
+package cppn
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
+	neatmath "github.com/yaricom/goNEAT/v4/neat/math"
+	"math"
+	"testing"
+)
+
+func TestWeightMappings(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mapping  WeightMapping
+		outputs  []float64
+		expected []float64
+	}{
+		{
+			name:     "linear",
+			mapping:  LinearWeightMapping{WeightRange: 3.0},
+			outputs:  []float64{-0.5, 0.0, 0.2},
+			expected: []float64{-1.5, 0.0, 0.6},
+		},
+		{
+			name:     "threshold normalized",
+			mapping:  ThresholdNormalizedWeightMapping{Threshold: 0.2, WeightRange: 3.0},
+			outputs:  []float64{-0.6, 0.1, 1.0},
+			expected: []float64{-1.5, 0.0, 3.0},
+		},
+		{
+			name:     "tanh",
+			mapping:  TanhWeightMapping{Gain: 2.0, WeightRange: 3.0},
+			outputs:  []float64{-0.5, 0.0, 10.0},
+			expected: []float64{-3.0 * math.Tanh(1.0), 0.0, 3.0 * math.Tanh(20.0)},
+		},
+		{
+			name:     "power",
+			mapping:  PowerWeightMapping{Exponent: 2.0, WeightRange: 3.0},
+			outputs:  []float64{-0.5, 0.1, 2.0},
+			expected: []float64{-0.75, 0.03, 3.0},
+		},
+		{
+			name:     "quantized",
+			mapping:  QuantizedWeightMapping{Levels: 3, WeightRange: 3.0},
+			outputs:  []float64{-2.0, -0.4, 0.2, 0.6},
+			expected: []float64{-3.0, 0.0, 0.0, 3.0},
+		},
+		{
+			name:     "quantized binary",
+			mapping:  QuantizedWeightMapping{Levels: 2, WeightRange: 1.0},
+			outputs:  []float64{-0.1, 0.1},
+			expected: []float64{-1.0, 1.0},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i, output := range tc.outputs {
+				assert.InDelta(t, tc.expected[i], tc.mapping.MapWeight(output), 1e-12, "wrong weight for output: %f", output)
+			}
+		})
+	}
+}
+
+func TestNewWeightMapping(t *testing.T) {
+	options := &hyperneat.Options{LinkThreshold: 0.2, WeightRange: 3.0}
+
+	mapping, err := NewWeightMapping(options)
+	require.NoError(t, err)
+	assert.Nil(t, mapping)
+
+	options.WeightMapping = hyperneat.WeightMappingTanh
+	mapping, err = NewWeightMapping(options)
+	require.NoError(t, err)
+	assert.Equal(t, TanhWeightMapping{Gain: 1.0, WeightRange: 3.0}, mapping)
+
+	options.WeightMapping = hyperneat.WeightMappingPower
+	_, err = NewWeightMapping(options)
+	assert.EqualError(t, err, "weight mapping exponent must be positive, got: 0.000000")
+
+	options.WeightMapping = hyperneat.WeightMappingQuantized
+	options.WeightQuantizationLevels = 1
+	_, err = NewWeightMapping(options)
+	assert.EqualError(t, err, "weight quantization levels must be at least 2, got: 1")
+
+	// the mapping from options overrides the default mapping of the link expression strategy
+	options.WeightQuantizationLevels = 5
+	strategy, err := NewLinkExpressionStrategy(options)
+	require.NoError(t, err)
+	assert.Equal(t, ThresholdLinkExpression{Threshold: 0.2, WeightRange: 3.0, Mapping: QuantizedWeightMapping{Levels: 5, WeightRange: 3.0}}, strategy)
+}
+
+func TestEvolvableSubstrate_CreateNetworkSolver_QuantizedWeights(t *testing.T) {
+	layout, err := NewMappedEvolvableSubstrateLayout(4, 2)
+	require.NoError(t, err, "failed to create layout")
+	substr := NewEvolvableSubstrate(layout, neatmath.SigmoidSteepenedActivation, neatmath.LinearActivation)
+
+	cppn, err := NetworkFromGenomeFile(cppnHyperNEATTestGenomePath)
+	require.NoError(t, err, "failed to read CPPN")
+	context, err := loadESHyperNeatOptions(esHyperNeatTestConfigFile)
+	require.NoError(t, err, "failed to read ESHyperNEAT context")
+	context.WeightMapping = hyperneat.WeightMappingQuantized
+	context.WeightQuantizationLevels = 5
+
+	builder := NewSubstrateGraphMLBuilder("", false)
+	_, err = substr.CreateNetworkSolver(cppn, builder, context)
+	require.NoError(t, err, "failed to create solver")
+
+	// check that all link weights are quantized
+	graph, err := builder.(*graphMLBuilder).graph()
+	require.NoError(t, err, "failed to get graph")
+	require.NotEmpty(t, graph.Edges)
+	levels := []float64{-context.WeightRange, -context.WeightRange / 2, 0, context.WeightRange / 2, context.WeightRange}
+	for _, edge := range graph.Edges {
+		attributes, err := edge.GetAttributes()
+		require.NoError(t, err, "failed to get edge attributes")
+		assert.Contains(t, levels, attributes[edgeAttrWeight], "weight is not quantized")
+	}
+}
@@ -2,6 +2,7 @@
 package eshyperneat
 
 import (
+	"fmt"
 	"github.com/pkg/errors"
 	"github.com/yaricom/goESHyperNEAT/v2/hyperneat"
 	"gopkg.in/yaml.v3"
@@ -9,6 +10,124 @@ import (
 	"os"
 )
 
+// VarianceFunctionType The type of function to estimate the variance of CPPN outputs within the quadtree node region
+type VarianceFunctionType string
+
+const (
+	// VarianceChildren The variance is estimated over the direct children of the quadtree node
+	VarianceChildren VarianceFunctionType = "children"
+	// VarianceSubtree The variance is estimated over all leaves of the quadtree node subtree as in the original
+	// ES-HyperNEAT
+	VarianceSubtree VarianceFunctionType = "subtree"
+)
+
+// VarianceOutputType The type of CPPN outputs to estimate the variance of
+type VarianceOutputType string
+
+const (
+	// VarianceOutputWeight The variance of the CPPN weight output
+	VarianceOutputWeight VarianceOutputType = "weight"
+	// VarianceOutputLeoWeighted The variance of the CPPN weight output multiplied by the CPPN LEO output, i.e., the
+	// regions without expressed links are regarded as uniform
+	VarianceOutputLeoWeighted VarianceOutputType = "leo_weighted"
+	// VarianceOutputAll The sum of variances of all CPPN outputs
+	VarianceOutputAll VarianceOutputType = "all"
+)
+
+// BandNeighbourhoodType The shape of neighbourhood of the quadtree node probed to decide whether the node is in a band
+type BandNeighbourhoodType string
+
+const (
+	// BandNeighbourhoodAxisAligned The four axis-aligned neighbours, or six for the octree node
+	BandNeighbourhoodAxisAligned BandNeighbourhoodType = "axis_aligned"
+	// BandNeighbourhoodDiagonal The eight neighbours including diagonal ones, or twenty six for the octree node
+	BandNeighbourhoodDiagonal BandNeighbourhoodType = "diagonal"
+)
+
+// BandProbeDistanceType The distance between the quadtree node and its neighbours probed for banding
+type BandProbeDistanceType string
+
+const (
+	// BandProbeDistanceNode The neighbours are probed at the distance of the parent node size, i.e., at the centers of
+	// adjacent regions of the same size as the probed node
+	BandProbeDistanceNode BandProbeDistanceType = "node"
+	// BandProbeDistanceChild The neighbours are probed at the distance of the probed node size, i.e., at the half of
+	// distance to the centers of adjacent regions
+	BandProbeDistanceChild BandProbeDistanceType = "child"
+)
+
+// BandBoundaryType The policy to handle the neighbours probed for banding outside the substrate bounds
+type BandBoundaryType string
+
+const (
+	// BandBoundaryQuery The CPPN is queried at the neighbour position regardless of the substrate bounds
+	BandBoundaryQuery BandBoundaryType = "query"
+	// BandBoundaryClamp The neighbour position is clamped to the substrate bounds
+	BandBoundaryClamp BandBoundaryType = "clamp"
+	// BandBoundaryIgnore The neighbour outside the substrate bounds is not considered
+	BandBoundaryIgnore BandBoundaryType = "ignore"
+)
+
+// ResourceLimitPolicyType The policy to apply when the resource limit of substrate generation is exceeded
+type ResourceLimitPolicyType string
+
+const (
+	// ResourceLimitPolicyError The substrate generation fails with the resource limit error
+	ResourceLimitPolicyError ResourceLimitPolicyType = "error"
+	// ResourceLimitPolicyTruncate The resources exceeding the limit are dropped and the substrate generated within
+	// the limits is used
+	ResourceLimitPolicyTruncate ResourceLimitPolicyType = "truncate"
+)
+
+// Region Defines the bounds of the substrate region explored by the quadtree to discover hidden nodes
+type Region struct {
+	// MinX The lower bound along X axis
+	MinX float64 `yaml:"min_x"`
+	// MaxX The upper bound along X axis
+	MaxX float64 `yaml:"max_x"`
+	// MinY The lower bound along Y axis
+	MinY float64 `yaml:"min_y"`
+	// MaxY The upper bound along Y axis
+	MaxY float64 `yaml:"max_y"`
+	// MinZ The lower bound along Z axis
+	MinZ float64 `yaml:"min_z,omitempty"`
+	// MaxZ The upper bound along Z axis. If equal to MinZ, the region is the plane at MinZ explored by quadtree,
+	// otherwise it is the volume explored by octree.
+	MaxZ float64 `yaml:"max_z,omitempty"`
+}
+
+// IsVolume Returns true if this region has non-zero extent along Z axis
+func (r Region) IsVolume() bool {
+	return r.MaxZ > r.MinZ
+}
+
+// Contains Returns true if the point with given coordinates is within this region including its boundary. The Z
+// coordinate is checked only if this region is the volume.
+func (r Region) Contains(x, y, z float64) bool {
+	if x < r.MinX || x > r.MaxX || y < r.MinY || y > r.MaxY {
+		return false
+	}
+	return !r.IsVolume() || z >= r.MinZ && z <= r.MaxZ
+}
+
+// Validate Checks that bounds of this region are consistent
+func (r Region) Validate() error {
+	if r.MaxX <= r.MinX {
+		return errors.Errorf("the upper X bound of region must be greater than the lower one: [%f, %f]", r.MinX, r.MaxX)
+	}
+	if r.MaxY <= r.MinY {
+		return errors.Errorf("the upper Y bound of region must be greater than the lower one: [%f, %f]", r.MinY, r.MaxY)
+	}
+	if r.MaxZ < r.MinZ {
+		return errors.Errorf("the upper Z bound of region can not be less than the lower one: [%f, %f]", r.MinZ, r.MaxZ)
+	}
+	return nil
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("[%f, %f] x [%f, %f] x [%f, %f]", r.MinX, r.MaxX, r.MinY, r.MaxY, r.MinZ, r.MaxZ)
+}
+
 // Options ES-HyperNEAT execution options
 type Options struct {
 	// The included HyperNEAT options
@@ -31,14 +150,158 @@ type Options struct {
 	// is in the band then no new connection will be added and as result no new hidden node will be introduced.
 	// The bigger this value the fewer connections/hidden nodes will be added, i.e. wide bands approximation.
 	BandingThreshold float64 `yaml:"banding_threshold"`
+	// BandNeighbourhood defines the shape of neighbourhood probed for banding. [default: axis_aligned]
+	BandNeighbourhood BandNeighbourhoodType `yaml:"band_neighbourhood,omitempty"`
+	// BandProbeDistance defines the distance to the neighbours probed for banding. [default: node]
+	BandProbeDistance BandProbeDistanceType `yaml:"band_probe_distance,omitempty"`
+	// BandBoundary defines how to handle the neighbours probed for banding outside the substrate bounds.
+	// [default: query]
+	BandBoundary BandBoundaryType `yaml:"band_boundary,omitempty"`
+	// VarianceFunction defines the region of the quadtree node to estimate the variance of CPPN outputs within, which is
+	// compared with DivisionThreshold and VarianceThreshold. [default: children]
+	VarianceFunction VarianceFunctionType `yaml:"variance_function,omitempty"`
+	// VarianceOutput defines the CPPN outputs to estimate the variance of. [default: weight]
+	VarianceOutput VarianceOutputType `yaml:"variance_output,omitempty"`
 
 	// Quadtree Dimensions
-	// The range of the tree. Typically set to 2.0
+	// The half-size of the region explored by the tree, which is centered at the origin, i.e., the value 1.0 defines
+	// the range [-1, 1]. Typically set to 1.0
 	Width  float64 `yaml:"width"`
 	Height float64 `yaml:"height"`
+	// Depth The range of the tree along Z axis. If positive, the octree is used instead of quadtree to discover hidden
+	// nodes within the substrate volume. Zero value keeps the substrate planar.
+	Depth float64 `yaml:"depth,omitempty"`
+	// Region The bounds of the substrate region explored by the quadtree. If set, it takes precedence over Width,
+	// Height, and Depth, which define the region centered at the origin.
+	Region *Region `yaml:"region,omitempty"`
+	// StrictRegion defines whether the input or output nodes outside the explored region should result in error rather
+	// than warning.
+	StrictRegion bool `yaml:"strict_region,omitempty"`
+
+	// HiddenAllowedRegions The regions where hidden nodes can be placed. If empty, the hidden nodes can be placed
+	// anywhere outside HiddenForbiddenRegions. The Z bounds of the region are considered only if it is the volume.
+	HiddenAllowedRegions []Region `yaml:"hidden_allowed_regions,omitempty"`
+	// HiddenForbiddenRegions The regions where hidden nodes can not be placed, e.g., the rows of input and output nodes.
+	// The Z bounds of the region are considered only if it is the volume.
+	HiddenForbiddenRegions []Region `yaml:"hidden_forbidden_regions,omitempty"`
 
 	// ESIterations defines how many times ES-HyperNEAT should iteratively discover new hidden nodes.
 	ESIterations int `yaml:"es_iterations"`
+
+	// DisableRecurrentHiddenLinks defines whether to drop the links from the hidden node to the hidden nodes discovered
+	// before it, which create recurrent connections. If set, only links to the hidden nodes discovered later are
+	// expressed.
+	DisableRecurrentHiddenLinks bool `yaml:"disable_recurrent_hidden_links,omitempty"`
+	// DisableSelfLoopLinks defines whether to drop the links from the hidden node to itself
+	DisableSelfLoopLinks bool `yaml:"disable_self_loop_links,omitempty"`
+	// OutputFeedbackLinks defines whether the output nodes can be linked back to the hidden nodes. The outgoing
+	// connectivity pattern of each output node is explored to find links to the discovered hidden nodes.
+	OutputFeedbackLinks bool `yaml:"output_feedback_links,omitempty"`
+
+	// PruneHiddenNodes defines whether to remove the hidden nodes that are not on any path from the inputs to the
+	// outputs along with their links from the created network.
+	PruneHiddenNodes bool `yaml:"prune_hidden_nodes,omitempty"`
+
+	// CppnCacheSize defines the maximal number of CPPN query results to be cached during creation of one network
+	// solver from the evolvable substrate. The cached results are reused when the same hypercube point is queried again,
+	// e.g., during band pruning. Zero value disables the cache.
+	CppnCacheSize int `yaml:"cppn_cache_size"`
+
+	// ExplorationWorkers defines the number of concurrent workers used to explore the connectivity patterns of the
+	// substrate nodes. Each worker uses its own copy of CPPN. Values less than two turn on sequential exploration. The
+	// exploration is sequential if MaxCppnQueries is set, to keep the explored patterns independent of the order of
+	// concurrent CPPN queries.
+	ExplorationWorkers int `yaml:"exploration_workers"`
+
+	// MaxHiddenNodes defines the maximal number of hidden nodes to be added to the evolvable substrate. Zero value
+	// means no limit.
+	MaxHiddenNodes int `yaml:"max_hidden_nodes,omitempty"`
+	// MaxLinks defines the maximal number of links to be expressed in the evolvable substrate. Zero value means no limit.
+	MaxLinks int `yaml:"max_links,omitempty"`
+	// MaxCppnQueries defines the maximal number of CPPN queries to be made by the quadtree exploration of the
+	// connectivity patterns and by the encoding of the output and hidden nodes properties. The queries answered from
+	// the cache are not counted. Zero value means no limit.
+	MaxCppnQueries int `yaml:"max_cppn_queries,omitempty"`
+	// MaxQuadTreeDepth defines the maximal depth of the quadtree built for each explored substrate node. Unlike
+	// MaximalDepth, which stops division silently, exceeding this limit is handled according to ResourceLimitPolicy.
+	// Zero value means no limit.
+	MaxQuadTreeDepth int `yaml:"max_quad_tree_depth,omitempty"`
+	// ResourceLimitPolicy defines what to do when any of resource limits above is exceeded. By default, the substrate
+	// generation fails with the resource limit error.
+	ResourceLimitPolicy ResourceLimitPolicyType `yaml:"resource_limit_policy,omitempty"`
+}
+
+// ExploredRegion Returns the substrate region explored by the quadtree. If Region is not set, the region is centered at
+// the origin and extends by Width, Height, and Depth in both directions along X, Y, and Z axes respectively.
+func (o *Options) ExploredRegion() Region {
+	if o.Region != nil {
+		return *o.Region
+	}
+	return Region{
+		MinX: -o.Width, MaxX: o.Width,
+		MinY: -o.Height, MaxY: o.Height,
+		MinZ: -o.Depth, MaxZ: o.Depth,
+	}
+}
+
+func (v *VarianceFunctionType) UnmarshalYAML(value *yaml.Node) error {
+	switch function := VarianceFunctionType(value.Value); function {
+	case VarianceChildren, VarianceSubtree:
+		*v = function
+	default:
+		return errors.Errorf("unsupported variance function type in ES-HyperNEAT options: %s", value.Value)
+	}
+	return nil
+}
+
+func (v *VarianceOutputType) UnmarshalYAML(value *yaml.Node) error {
+	switch output := VarianceOutputType(value.Value); output {
+	case VarianceOutputWeight, VarianceOutputLeoWeighted, VarianceOutputAll:
+		*v = output
+	default:
+		return errors.Errorf("unsupported variance output type in ES-HyperNEAT options: %s", value.Value)
+	}
+	return nil
+}
+
+func (b *BandNeighbourhoodType) UnmarshalYAML(value *yaml.Node) error {
+	switch neighbourhood := BandNeighbourhoodType(value.Value); neighbourhood {
+	case BandNeighbourhoodAxisAligned, BandNeighbourhoodDiagonal:
+		*b = neighbourhood
+	default:
+		return errors.Errorf("unsupported band neighbourhood type in ES-HyperNEAT options: %s", value.Value)
+	}
+	return nil
+}
+
+func (b *BandProbeDistanceType) UnmarshalYAML(value *yaml.Node) error {
+	switch distance := BandProbeDistanceType(value.Value); distance {
+	case BandProbeDistanceNode, BandProbeDistanceChild:
+		*b = distance
+	default:
+		return errors.Errorf("unsupported band probe distance type in ES-HyperNEAT options: %s", value.Value)
+	}
+	return nil
+}
+
+func (b *BandBoundaryType) UnmarshalYAML(value *yaml.Node) error {
+	switch boundary := BandBoundaryType(value.Value); boundary {
+	case BandBoundaryQuery, BandBoundaryClamp, BandBoundaryIgnore:
+		*b = boundary
+	default:
+		return errors.Errorf("unsupported band boundary type in ES-HyperNEAT options: %s", value.Value)
+	}
+	return nil
+}
+
+func (r *ResourceLimitPolicyType) UnmarshalYAML(value *yaml.Node) error {
+	switch policy := ResourceLimitPolicyType(value.Value); policy {
+	case ResourceLimitPolicyError, ResourceLimitPolicyTruncate:
+		*r = policy
+	default:
+		return errors.Errorf("unsupported resource limit policy type in ES-HyperNEAT options: %s", value.Value)
+	}
+	return nil
 }
 
 // LoadYAMLOptions is to load ES-HyperNEAT options from provided reader
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/yaricom/goNEAT/v4/neat/math"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -29,6 +30,100 @@ func TestLoadYAMLOptions(t *testing.T) {
 	checkEsHyperNeatOptions(opts, t)
 }
 
+func TestVarianceFunctionType_UnmarshalYAML(t *testing.T) {
+	config := "variance_function: subtree\nvariance_output: leo_weighted\n"
+	opts, err := LoadYAMLOptions(strings.NewReader(config))
+	require.NoError(t, err, "failed to load ES-HyperNEAT options")
+	assert.Equal(t, VarianceSubtree, opts.VarianceFunction)
+	assert.Equal(t, VarianceOutputLeoWeighted, opts.VarianceOutput)
+
+	// unsupported function
+	_, err = LoadYAMLOptions(strings.NewReader("variance_function: unknown\n"))
+	assert.Error(t, err)
+
+	// unsupported output
+	_, err = LoadYAMLOptions(strings.NewReader("variance_output: unknown\n"))
+	assert.Error(t, err)
+}
+
+func TestBandOptions_UnmarshalYAML(t *testing.T) {
+	config := "band_neighbourhood: diagonal\nband_probe_distance: child\nband_boundary: ignore\n"
+	opts, err := LoadYAMLOptions(strings.NewReader(config))
+	require.NoError(t, err, "failed to load ES-HyperNEAT options")
+	assert.Equal(t, BandNeighbourhoodDiagonal, opts.BandNeighbourhood)
+	assert.Equal(t, BandProbeDistanceChild, opts.BandProbeDistance)
+	assert.Equal(t, BandBoundaryIgnore, opts.BandBoundary)
+
+	for _, config = range []string{"band_neighbourhood: unknown\n", "band_probe_distance: unknown\n", "band_boundary: unknown\n"} {
+		_, err = LoadYAMLOptions(strings.NewReader(config))
+		assert.Error(t, err, config)
+	}
+}
+
+func TestResourceLimits_UnmarshalYAML(t *testing.T) {
+	config := "max_hidden_nodes: 100\nmax_links: 500\nmax_cppn_queries: 100000\nmax_quad_tree_depth: 6\nresource_limit_policy: truncate\n"
+	opts, err := LoadYAMLOptions(strings.NewReader(config))
+	require.NoError(t, err, "failed to load ES-HyperNEAT options")
+	assert.Equal(t, 100, opts.MaxHiddenNodes)
+	assert.Equal(t, 500, opts.MaxLinks)
+	assert.Equal(t, 100000, opts.MaxCppnQueries)
+	assert.Equal(t, 6, opts.MaxQuadTreeDepth)
+	assert.Equal(t, ResourceLimitPolicyTruncate, opts.ResourceLimitPolicy)
+
+	// unsupported policy
+	_, err = LoadYAMLOptions(strings.NewReader("resource_limit_policy: unknown\n"))
+	assert.Error(t, err)
+}
+
+func TestOptions_ExploredRegion(t *testing.T) {
+	opts := &Options{Width: 1.0, Height: 2.0}
+	region := opts.ExploredRegion()
+	assert.Equal(t, Region{MinX: -1.0, MaxX: 1.0, MinY: -2.0, MaxY: 2.0}, region)
+	assert.False(t, region.IsVolume())
+
+	opts.Depth = 0.5
+	assert.True(t, opts.ExploredRegion().IsVolume())
+
+	// explicit region takes precedence
+	config := "width: 1.0\nheight: 1.0\nregion:\n  min_x: 0.0\n  max_x: 2.0\n  min_y: -1.0\n  max_y: 0.0\n  max_z: 1.0\n"
+	opts, err := LoadYAMLOptions(strings.NewReader(config))
+	require.NoError(t, err, "failed to load ES-HyperNEAT options")
+	assert.Equal(t, Region{MinX: 0.0, MaxX: 2.0, MinY: -1.0, MaxY: 0.0, MaxZ: 1.0}, opts.ExploredRegion())
+}
+
+func TestRegion_Contains(t *testing.T) {
+	planar := Region{MinX: -1.0, MaxX: 1.0, MinY: 0.0, MaxY: 1.0}
+	assert.True(t, planar.Contains(-1.0, 1.0, 5.0), "Z should not be checked for planar region")
+	assert.False(t, planar.Contains(0.0, -0.5, 0.0))
+	assert.False(t, planar.Contains(1.5, 0.5, 0.0))
+
+	volume := Region{MinX: -1.0, MaxX: 1.0, MinY: 0.0, MaxY: 1.0, MinZ: -1.0, MaxZ: 1.0}
+	assert.True(t, volume.Contains(0.0, 0.5, 1.0))
+	assert.False(t, volume.Contains(0.0, 0.5, 1.5))
+}
+
+func TestRegion_Validate(t *testing.T) {
+	assert.NoError(t, Region{MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: 1.0}.Validate())
+	assert.Error(t, Region{MinX: 1.0, MaxX: 1.0, MinY: -1.0, MaxY: 1.0}.Validate())
+	assert.Error(t, Region{MinX: -1.0, MaxX: 1.0, MinY: 1.0, MaxY: -1.0}.Validate())
+	assert.Error(t, Region{MinX: -1.0, MaxX: 1.0, MinY: -1.0, MaxY: 1.0, MinZ: 1.0}.Validate())
+}
+
+func TestHiddenRegions_UnmarshalYAML(t *testing.T) {
+	config := `
+hidden_allowed_regions:
+  - {min_x: -1.0, max_x: 1.0, min_y: -0.5, max_y: 0.5}
+hidden_forbidden_regions:
+  - {min_x: -1.0, max_x: 1.0, min_y: -1.0, max_y: -1.0}
+  - {min_x: -1.0, max_x: 1.0, min_y: 1.0, max_y: 1.0}
+`
+	opts, err := LoadYAMLOptions(strings.NewReader(config))
+	require.NoError(t, err, "failed to load ES-HyperNEAT options")
+	assert.Equal(t, []Region{{MinX: -1.0, MaxX: 1.0, MinY: -0.5, MaxY: 0.5}}, opts.HiddenAllowedRegions)
+	require.Len(t, opts.HiddenForbiddenRegions, 2)
+	assert.Equal(t, Region{MinX: -1.0, MaxX: 1.0, MinY: 1.0, MaxY: 1.0}, opts.HiddenForbiddenRegions[1])
+}
+
 func checkEsHyperNeatOptions(opts *Options, t *testing.T) {
 	assert.Equal(t, 3, opts.InitialDepth)
 	assert.Equal(t, 5, opts.MaximalDepth)
@@ -36,6 +131,7 @@ func checkEsHyperNeatOptions(opts *Options, t *testing.T) {
 	assert.Equal(t, 0.03, opts.VarianceThreshold)
 	assert.Equal(t, 0.3, opts.BandingThreshold)
 	assert.Equal(t, 1, opts.ESIterations)
+	assert.Equal(t, 10000, opts.CppnCacheSize)
 
 	assert.Equal(t, math.SigmoidSteepenedActivation, opts.SubstrateActivator.SubstrateActivationType)
 	assert.Equal(t, math.SigmoidPlainActivation, opts.OutputActivator.OutputActivationType)
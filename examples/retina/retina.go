@@ -196,7 +196,7 @@ func (e *generationEvaluator) organismEvaluate(ctx context.Context, organism *ge
 		layout, options.SubstrateActivator.SubstrateActivationType, options.OutputActivator.OutputActivationType, options.CppnBias)
 	graph := cppn.NewSubstrateGraphMLBuilder("retina ES-HyperNEAT", false)
 	createSolverTime := time.Now()
-	solver, err := substr.CreateNetworkSolver(cppnSolver, graph, options)
+	solver, err := substr.CreateNetworkSolverContext(ctx, cppnSolver, graph, options)
 	if err != nil {
 		return false, nil, errors.Wrap(err, fmt.Sprintf("failed to evaluate organism: %s", organism))
 	}
@@ -250,6 +250,12 @@ func (e *generationEvaluator) organismEvaluate(ctx context.Context, organism *ge
 		neat.InfoLog(fmt.Sprintf("Substrate: nodes = %d, edges = %d | CPPN phenotype: nodes = %d, edges = %d",
 			solver.NodeCount(), solver.LinkCount(), cppnSolver.NodeCount(), cppnSolver.LinkCount()))
 		neat.InfoLog(fmt.Sprintf("Substrate: evaluation time = %v, create solver time = %v", elapsed, createSolverElapsedTime))
+		if options.CppnCacheSize > 0 {
+			neat.InfoLog(fmt.Sprintf("Substrate: CPPN query cache %s", substr.QueryCacheStats()))
+		}
+		for _, iterationStats := range substr.IterationStats() {
+			neat.InfoLog(fmt.Sprintf("Substrate: hidden nodes discovery %s", iterationStats))
+		}
 	}
 
 	return isWinner, solver, nil
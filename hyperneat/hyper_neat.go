@@ -17,6 +17,45 @@ type OutputActivatorType struct {
 	OutputActivationType math.NodeActivationType
 }
 
+type NodeActivatorsPaletteType struct {
+	ActivationTypes []math.NodeActivationType
+}
+
+// LinkExpressionType The type of strategy to decide whether a link between two substrate nodes should be expressed
+type LinkExpressionType string
+
+const (
+	// LinkExpressionThreshold The link is expressed if absolute value of the CPPN weight output exceeds LinkThreshold
+	LinkExpressionThreshold LinkExpressionType = "threshold"
+	// LinkExpressionLeo The link is expressed if the CPPN LEO output exceeds LeoThreshold
+	LinkExpressionLeo LinkExpressionType = "leo"
+	// LinkExpressionLeoThreshold The link is expressed if the CPPN LEO output exceeds LeoThreshold and absolute value
+	// of the CPPN weight output exceeds LinkThreshold
+	LinkExpressionLeoThreshold LinkExpressionType = "leo_threshold"
+	// LinkExpressionLeoGaussianLocality The link is expressed if the CPPN LEO output seeded with the Gaussian of the
+	// distance between linked nodes exceeds LeoThreshold. It promotes local connectivity.
+	LinkExpressionLeoGaussianLocality LinkExpressionType = "leo_gaussian_locality"
+)
+
+// WeightMappingType The type of function to map the CPPN weight output into the weight of substrate link
+type WeightMappingType string
+
+const (
+	// WeightMappingLinear The CPPN output is scaled to fit WeightRange
+	WeightMappingLinear WeightMappingType = "linear"
+	// WeightMappingThresholdNormalized The CPPN output exceeding LinkThreshold is normalized and scaled to fit WeightRange
+	WeightMappingThresholdNormalized WeightMappingType = "threshold_normalized"
+	// WeightMappingTanh The CPPN output multiplied by WeightMappingGain is squashed by hyperbolic tangent and scaled to
+	// fit WeightRange
+	WeightMappingTanh WeightMappingType = "tanh"
+	// WeightMappingPower The CPPN output magnitude is raised to the power of WeightMappingExponent and scaled to fit
+	// WeightRange
+	WeightMappingPower WeightMappingType = "power"
+	// WeightMappingQuantized The CPPN output is mapped to the nearest of WeightQuantizationLevels discrete weights
+	// evenly spaced within WeightRange
+	WeightMappingQuantized WeightMappingType = "quantized"
+)
+
 // Options The HyperNEAT execution options
 type Options struct {
 	// LinkThreshold The threshold value to indicate which links should be included
@@ -25,14 +64,66 @@ type Options struct {
 	// from -WeightRange to +WeightRange, and can be any integer
 	WeightRange float64 `yaml:"weight_range"`
 
+	// WeightMapping The type of function to map the CPPN weight output into the weight of substrate link. If not set,
+	// the threshold normalization is used by weight threshold strategies of link expression, and linear scaling otherwise.
+	WeightMapping WeightMappingType `yaml:"weight_mapping,omitempty"`
+	// WeightMappingGain The multiplier of the CPPN output for the tanh weight mapping [default: 1.0]
+	WeightMappingGain float64 `yaml:"weight_mapping_gain,omitempty"`
+	// WeightMappingExponent The exponent for the power weight mapping
+	WeightMappingExponent float64 `yaml:"weight_mapping_exponent,omitempty"`
+	// WeightQuantizationLevels The number of discrete weight levels for the quantized weight mapping
+	WeightQuantizationLevels int `yaml:"weight_quantization_levels,omitempty"`
+
 	// LeoEnabled flag to control if Link Expression Output (LEO) enabled
 	LeoEnabled bool `yaml:"leo_enabled"`
 
+	// LinkExpression The type of strategy to decide whether a link between substrate nodes should be expressed. If not
+	// set, the LEO or weight threshold strategy is used depending on whether LEO is enabled.
+	LinkExpression LinkExpressionType `yaml:"link_expression,omitempty"`
+	// LeoThreshold The threshold value the CPPN LEO output should exceed to express a link
+	LeoThreshold float64 `yaml:"leo_threshold,omitempty"`
+	// LeoLocalitySigma The standard deviation of the Gaussian of the distance between linked nodes which seeds
+	// the LEO output with the locality when LinkExpressionLeoGaussianLocality strategy is used
+	LeoLocalitySigma float64 `yaml:"leo_locality_sigma,omitempty"`
+
+	// NodeBiasEnabled flag to control if the biases of the hidden and output substrate nodes are encoded by CPPN. The
+	// CPPN is queried at each node position with origin as a target, i.e., (x, y, z, 0, 0, 0), and the scaled by
+	// WeightRange output value is used as a node bias.
+	NodeBiasEnabled bool `yaml:"node_bias_enabled"`
+	// NodeBiasOutput The index of the CPPN output to read node bias value from. The weight output is used by default.
+	NodeBiasOutput int `yaml:"node_bias_output,omitempty"`
+
 	// SubstrateActivator The activation function for the hidden substrate nodes
 	SubstrateActivator SubstrateActivatorType `yaml:"substrate_activator"`
 	// OutputActivatorType The activation function for the output substrate nodes
 	OutputActivator OutputActivatorType `yaml:"output_activator"`
 
+	// NodeActivatorsPalette The list of activation functions to be selected by CPPN for each hidden and output
+	// substrate node. If defined, the CPPN is queried at each node position, i.e., (x, y, z, 0, 0, 0), and the activation
+	// function corresponding to the CPPN output with maximal value is selected.
+	NodeActivatorsPalette NodeActivatorsPaletteType `yaml:"node_activators_palette,omitempty"`
+	// NodeActivatorsOutput The index of the first CPPN output to select activation function from the palette. The
+	// number of CPPN outputs used for selection is equal to the size of the palette.
+	NodeActivatorsOutput int `yaml:"node_activators_output,omitempty"`
+
+	// PlasticityEnabled flag to control if the substrate links are plastic, i.e., their weights are updated online
+	// during network activation according to the generalized Hebbian rule: Δw = η·(A·pre·post + B·pre + C·post + D).
+	// The rule coefficients and the learning rate η of each link are read from the CPPN outputs queried for that link.
+	PlasticityEnabled bool `yaml:"plasticity_enabled,omitempty"`
+	// PlasticityOutput The index of the first of five consecutive CPPN outputs to read the coefficients of the link
+	// learning rule from, in order: A, B, C, D, and learning rate.
+	PlasticityOutput int `yaml:"plasticity_output,omitempty"`
+
+	// NeuromodulationEnabled flag to control if the hidden substrate nodes can be modulatory. The CPPN is queried at each
+	// hidden node position, i.e., (x, y, z, 0, 0, 0), and the node is modulatory if the output value exceeds
+	// NeuromodulationThreshold. The modulatory nodes do not contribute to the activation of their targets, but scale
+	// the Hebbian weights updates of the incoming links of their targets. Requires plasticity to be enabled.
+	NeuromodulationEnabled bool `yaml:"neuromodulation_enabled,omitempty"`
+	// NeuromodulationOutput The index of the CPPN output to decide whether the node is modulatory
+	NeuromodulationOutput int `yaml:"neuromodulation_output,omitempty"`
+	// NeuromodulationThreshold The threshold value the CPPN output should exceed for the node to be modulatory
+	NeuromodulationThreshold float64 `yaml:"neuromodulation_threshold,omitempty"`
+
 	// CppnBias The BIAS value for CPPN network
 	CppnBias float64 `yaml:"cppn_bias,omitempty"`
 }
@@ -81,3 +172,39 @@ func (o *OutputActivatorType) UnmarshalYAML(value *yaml.Node) error {
 	}
 	return nil
 }
+
+func (p *NodeActivatorsPaletteType) UnmarshalYAML(value *yaml.Node) error {
+	var names []string
+	if err := value.Decode(&names); err != nil {
+		return errors.Wrap(err, "failed to decode node activators palette from HyperNEAT options")
+	}
+	p.ActivationTypes = make([]math.NodeActivationType, len(names))
+	for i, name := range names {
+		if activationType, err := math.NodeActivators.ActivationTypeFromName(name); err != nil {
+			return errors.Wrap(err, "failed to decode node activators palette from HyperNEAT options")
+		} else {
+			p.ActivationTypes[i] = activationType
+		}
+	}
+	return nil
+}
+
+func (l *LinkExpressionType) UnmarshalYAML(value *yaml.Node) error {
+	switch expression := LinkExpressionType(value.Value); expression {
+	case LinkExpressionThreshold, LinkExpressionLeo, LinkExpressionLeoThreshold, LinkExpressionLeoGaussianLocality:
+		*l = expression
+	default:
+		return errors.Errorf("unsupported link expression type in HyperNEAT options: %s", value.Value)
+	}
+	return nil
+}
+
+func (w *WeightMappingType) UnmarshalYAML(value *yaml.Node) error {
+	switch mapping := WeightMappingType(value.Value); mapping {
+	case WeightMappingLinear, WeightMappingThresholdNormalized, WeightMappingTanh, WeightMappingPower, WeightMappingQuantized:
+		*w = mapping
+	default:
+		return errors.Errorf("unsupported weight mapping type in HyperNEAT options: %s", value.Value)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/yaricom/goNEAT/v4/neat/math"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -35,3 +36,45 @@ func checkHyperNeatOptions(opts *Options, t *testing.T) {
 	assert.Equal(t, 0.2, opts.LinkThreshold)
 	assert.Equal(t, 3.0, opts.WeightRange)
 }
+
+func TestNodeActivatorsPaletteType_UnmarshalYAML(t *testing.T) {
+	config := "node_activators_palette: [SigmoidSteepenedActivation, TanhActivation, GaussianBipolarActivation]\n" +
+		"node_activators_output: 1\n"
+	opts, err := LoadYAMLOptions(strings.NewReader(config))
+	require.NoError(t, err, "failed to load HyperNEAT options")
+
+	expected := []math.NodeActivationType{math.SigmoidSteepenedActivation, math.TanhActivation, math.GaussianBipolarActivation}
+	assert.Equal(t, expected, opts.NodeActivatorsPalette.ActivationTypes)
+	assert.Equal(t, 1, opts.NodeActivatorsOutput)
+
+	// unknown activator
+	_, err = LoadYAMLOptions(strings.NewReader("node_activators_palette: [UnknownActivation]\n"))
+	assert.Error(t, err)
+}
+
+func TestLinkExpressionType_UnmarshalYAML(t *testing.T) {
+	config := "link_expression: leo_gaussian_locality\nleo_threshold: 0.1\nleo_locality_sigma: 0.5\n"
+	opts, err := LoadYAMLOptions(strings.NewReader(config))
+	require.NoError(t, err, "failed to load HyperNEAT options")
+	assert.Equal(t, LinkExpressionLeoGaussianLocality, opts.LinkExpression)
+	assert.Equal(t, 0.1, opts.LeoThreshold)
+	assert.Equal(t, 0.5, opts.LeoLocalitySigma)
+
+	// unsupported strategy
+	_, err = LoadYAMLOptions(strings.NewReader("link_expression: unknown\n"))
+	assert.Error(t, err)
+}
+
+func TestWeightMappingType_UnmarshalYAML(t *testing.T) {
+	config := "weight_mapping: quantized\nweight_quantization_levels: 5\nweight_mapping_gain: 2\nweight_mapping_exponent: 1.5\n"
+	opts, err := LoadYAMLOptions(strings.NewReader(config))
+	require.NoError(t, err, "failed to load HyperNEAT options")
+	assert.Equal(t, WeightMappingQuantized, opts.WeightMapping)
+	assert.Equal(t, 5, opts.WeightQuantizationLevels)
+	assert.Equal(t, 2.0, opts.WeightMappingGain)
+	assert.Equal(t, 1.5, opts.WeightMappingExponent)
+
+	// unsupported mapping
+	_, err = LoadYAMLOptions(strings.NewReader("weight_mapping: unknown\n"))
+	assert.Error(t, err)
+}